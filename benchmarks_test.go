@@ -24,6 +24,7 @@ package resp
 import (
 	"encoding/json"
 	"log"
+	"strconv"
 	"testing"
 )
 
@@ -44,6 +45,8 @@ var (
 	benchmarkRESPEncodedArrayInteger = mustRESPEncode(benchmarkArrayInteger)
 	benchmarkRESPEncodedArrayArray   = mustRESPEncode(benchmarkArrayArray)
 
+	benchmarkRESPEncodedLRange = mustRESPEncode(benchmarkLRange(100))
+
 	benchmarkJSONEncodedString       = mustJSONEncode(benchmarkString)
 	benchmarkJSONEncodedBytes        = mustJSONEncode(benchmarkBytes)
 	benchmarkJSONEncodedInteger      = mustJSONEncode(benchmarkInteger)
@@ -53,6 +56,26 @@ var (
 	benchmarkJSONEncodedArrayArray   = mustJSONEncode(benchmarkArrayArray)
 )
 
+// loopReader returns the same data over and over again.
+type loopReader struct {
+	data []byte
+	off  int
+}
+
+func (r *loopReader) Read(p []byte) (int, error) {
+	n := copy(p, r.data[r.off:])
+	r.off = (r.off + n) % len(r.data)
+	return n, nil
+}
+
+func benchmarkLRange(n int) [][]byte {
+	values := make([][]byte, n)
+	for i := range values {
+		values[i] = []byte("list-element-" + strconv.Itoa(i))
+	}
+	return values
+}
+
 func mustJSONEncode(i interface{}) []byte {
 	b, e := json.Marshal(i)
 	if e != nil {
@@ -364,3 +387,109 @@ func BenchmarkRESPUnmarshalArrayArray(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkRESPDecodeLRange(b *testing.B) {
+	var err error
+	var m Message
+
+	d := NewDecoder(&loopReader{data: benchmarkRESPEncodedLRange})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err = d.Decode(&m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRESPDecodeMessageLRange(b *testing.B) {
+	var err error
+
+	d := NewDecoder(&loopReader{data: benchmarkRESPEncodedLRange})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		m := AcquireMessage()
+		if err = d.DecodeMessage(m); err != nil {
+			b.Fatal(err)
+		}
+		ReleaseMessage(m)
+	}
+}
+
+func BenchmarkRESPDecodeMessageLRangeZeroCopy(b *testing.B) {
+	var err error
+
+	d := NewDecoder(&loopReader{data: benchmarkRESPEncodedLRange})
+	d.SetZeroCopy(true)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		m := AcquireMessage()
+		if err = d.DecodeMessage(m); err != nil {
+			b.Fatal(err)
+		}
+		ReleaseMessage(m)
+	}
+}
+
+func BenchmarkReaderReadLine(b *testing.B) {
+	var err error
+
+	r := NewReader(&loopReader{data: []byte("+OK\r\n:1234567890\r\n*100\r\n-ERR unknown command 'foo'\r\n")})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err = r.ReadLine(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReaderReadLongLine(b *testing.B) {
+	var err error
+
+	line := make([]byte, 0, 10*1024)
+	line = append(line, StringHeader)
+	for len(line) < cap(line)-len(endOfLine) {
+		line = append(line, 'a')
+	}
+	line = append(line, endOfLine...)
+
+	r := NewReader(&loopReader{data: line})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err = r.ReadLine(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRESPDecodeMessageIntegers(b *testing.B) {
+	var err error
+
+	d := NewDecoder(&loopReader{data: benchmarkRESPEncodedArrayInteger})
+
+	m := AcquireMessage()
+	defer ReleaseMessage(m)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err = d.DecodeMessage(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2015 José Carlos Nieto, https://menteslibres.net/xiam
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the
+// "Software"), to deal in the Software without restriction, including
+// without limitation the rights to use, copy, modify, merge, publish,
+// distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to
+// the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+// LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+// WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package resp
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BlockingTimeout returns the time a blocking command like BLPOP, BLMOVE,
+// BZPOPMIN, XREAD BLOCK or WAIT may wait on the server before replying, as
+// given by its arguments. A zero timeout means that the command blocks until
+// it can reply. The second value is false if c is not a blocking command.
+func BlockingTimeout(c *Cmd) (time.Duration, bool) {
+	args := c.Args()
+	if len(args) < 2 {
+		return 0, false
+	}
+
+	switch strings.ToUpper(c.Name()) {
+	case "BLPOP", "BRPOP", "BRPOPLPUSH", "BLMOVE", "BZPOPMIN", "BZPOPMAX":
+		return parseTimeout(args[len(args)-1], time.Second)
+	case "BLMPOP", "BZMPOP":
+		return parseTimeout(args[1], time.Second)
+	case "WAIT", "WAITAOF":
+		return parseTimeout(args[len(args)-1], time.Millisecond)
+	case "XREAD", "XREADGROUP":
+		for i := 1; i+1 < len(args); i++ {
+			if strings.EqualFold(string(args[i]), "STREAMS") {
+				break
+			}
+			if strings.EqualFold(string(args[i]), "BLOCK") {
+				return parseTimeout(args[i+1], time.Millisecond)
+			}
+		}
+	}
+
+	return 0, false
+}
+
+func parseTimeout(arg []byte, unit time.Duration) (time.Duration, bool) {
+	f, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || f < 0 {
+		return 0, false
+	}
+	return time.Duration(f * float64(unit)), true
+}
+
+// DoBlocking writes c to e and reads its reply from d into the value pointed to
+// by v. The reply must arrive within readTimeout plus the time the command may
+// block on the server (see BlockingTimeout), so a readTimeout meant for
+// regular commands doesn't break blocking ones. A readTimeout of zero or less
+// means no timeout. If the command timed out on the server and replied with
+// nil, ErrBlockTimeout is returned. As with Do, error replies are returned as
+// errors and the reply is discarded if v is nil.
+//
+// If ctx is done before the reply arrives ctx.Err() is returned. The server
+// still sends the reply later on, so after any error reading the reply d is
+// poisoned (see Poisoned) and the connection has to be closed.
+func DoBlocking(ctx context.Context, e *Encoder, d *Decoder, c *Cmd, readTimeout time.Duration, v interface{}) error {
+	if d.err != nil {
+		return ErrDecoderPoisoned
+	}
+
+	timeout, blocking := BlockingTimeout(c)
+
+	if err := e.EncodeContext(ctx, c); err != nil {
+		return err
+	}
+
+	if readTimeout > 0 && !(blocking && timeout == 0) {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, readTimeout+timeout)
+		defer cancel()
+	}
+
+	var reply Message
+	if err := d.DecodeContext(ctx, &reply); err != nil {
+		// The reply may still arrive, and would be read as the reply to the
+		// next command.
+		if d.err == nil {
+			d.err = err
+		}
+		return err
+	}
+
+	if reply.Type == ErrorHeader {
+		return replyError(&reply)
+	}
+
+	if blocking && reply.IsNil {
+		return ErrBlockTimeout
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return messageToValue(&reply, v)
+}
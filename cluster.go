@@ -0,0 +1,172 @@
+// Copyright (c) 2015 José Carlos Nieto, https://menteslibres.net/xiam
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the
+// "Software"), to deal in the Software without restriction, including
+// without limitation the rights to use, copy, modify, merge, publish,
+// distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to
+// the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+// LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+// WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package resp
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Number of hash slots of a redis cluster.
+const clusterSlots = 16384
+
+// Lookup table for the CRC16 variant used by redis cluster (XMODEM,
+// polynomial 0x1021).
+var crc16Table [256]uint16
+
+func init() {
+	for i := range crc16Table {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		crc16Table[i] = crc
+	}
+}
+
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^s[i]]
+	}
+	return crc
+}
+
+// HashSlot returns the cluster hash slot of a key. If the key contains a
+// non-empty hash tag like "{user1000}.following", only the tag is hashed, so
+// keys that share a tag are stored in the same slot.
+func HashSlot(key string) int {
+	if i := strings.IndexByte(key, '{'); i >= 0 {
+		if j := strings.IndexByte(key[i+1:], '}'); j > 0 {
+			key = key[i+1 : i+1+j]
+		}
+	}
+	return int(crc16(key)) % clusterSlots
+}
+
+// Redirect is the destination given by a MOVED or ASK error. After MOVED the
+// slot map is outdated and should be loaded again, after ASK only the next
+// command is sent to Addr, preceded by ASKING.
+type Redirect struct {
+	Ask  bool
+	Slot int
+	Addr string
+}
+
+// ParseRedirect returns the destination of a MOVED or ASK error reply, or nil
+// if err is not a redirection.
+func ParseRedirect(err error) *Redirect {
+	var ask bool
+
+	switch ErrorCode(err) {
+	case "MOVED":
+	case "ASK":
+		ask = true
+	default:
+		return nil
+	}
+
+	fields := strings.Fields(err.Error())
+	if len(fields) != 3 {
+		return nil
+	}
+
+	slot, err := strconv.Atoi(fields[1])
+	if err != nil || slot < 0 || slot >= clusterSlots {
+		return nil
+	}
+
+	return &Redirect{Ask: ask, Slot: slot, Addr: fields[2]}
+}
+
+// ClusterNode is a node that serves a range of slots.
+type ClusterNode struct {
+	Addr string
+	ID   string
+}
+
+// ClusterSlots is a range of slots and the nodes that serve it, as returned
+// by CLUSTER SLOTS. The first node is the master.
+type ClusterSlots struct {
+	Start int
+	End   int
+	Nodes []ClusterNode
+}
+
+// UnmarshalRESP decodes a [start, end, [ip, port, id], ...] array.
+func (c *ClusterSlots) UnmarshalRESP(m *Message) error {
+	*c = ClusterSlots{}
+
+	a, err := m.Slice()
+	if err != nil {
+		return err
+	}
+
+	if len(a) < 3 {
+		return ErrInvalidInput
+	}
+
+	var n int64
+
+	if n, err = a[0].Int64(); err != nil {
+		return err
+	}
+	c.Start = int(n)
+
+	if n, err = a[1].Int64(); err != nil {
+		return err
+	}
+	c.End = int(n)
+
+	c.Nodes = make([]ClusterNode, len(a)-2)
+	for i := range c.Nodes {
+		var node []*Message
+		if node, err = a[i+2].Slice(); err != nil {
+			return err
+		}
+		if len(node) < 2 {
+			return ErrInvalidInput
+		}
+
+		var host string
+		if host, err = node[0].Str(); err != nil {
+			return err
+		}
+		if n, err = node[1].Int64(); err != nil {
+			return err
+		}
+		c.Nodes[i].Addr = net.JoinHostPort(host, strconv.FormatInt(n, 10))
+
+		if len(node) > 2 {
+			if c.Nodes[i].ID, err = node[2].Str(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2015 José Carlos Nieto, https://menteslibres.net/xiam
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the
+// "Software"), to deal in the Software without restriction, including
+// without limitation the rights to use, copy, modify, merge, publish,
+// distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to
+// the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+// LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+// WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package resp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Cmd is a command that is always encoded as an array of bulk messages, which
+// is what redis expects from clients. Arguments are converted into bulk
+// messages according to their type:
+//
+//	string, []byte: as is.
+//	int, int8, ..., uint64: decimal representation.
+//	float32, float64: decimal representation, infinities are "+inf" and "-inf".
+//	bool: "1" or "0".
+//	time.Duration: number of seconds, e.g. "1.5".
+//	fmt.Stringer: the value returned by String().
+//
+// Any other type is an error that is reported by Err and when encoding the
+// command.
+type Cmd struct {
+	args [][]byte
+	err  error
+}
+
+// NewCmd creates a command with the given name and arguments.
+func NewCmd(name string, args ...interface{}) *Cmd {
+	c := &Cmd{
+		args: make([][]byte, 0, 1+len(args)),
+	}
+	c.args = append(c.args, []byte(name))
+	return c.Arg(args...)
+}
+
+// Arg appends arguments to the command.
+func (c *Cmd) Arg(args ...interface{}) *Cmd {
+	for i := range args {
+		b, err := argToBytes(args[i])
+		if err != nil {
+			if c.err == nil {
+				c.err = err
+			}
+			continue
+		}
+		c.args = append(c.args, b)
+	}
+	return c
+}
+
+// Flag appends name to the command if on is true, e.g. Flag("WITHSCORES",
+// true).
+func (c *Cmd) Flag(name string, on bool) *Cmd {
+	if on {
+		c.args = append(c.args, []byte(name))
+	}
+	return c
+}
+
+// Opt appends name followed by value to the command, e.g. Opt("COUNT", 10).
+func (c *Cmd) Opt(name string, value interface{}) *Cmd {
+	c.args = append(c.args, []byte(name))
+	return c.Arg(value)
+}
+
+// Pairs appends a list of key/value pairs to the command, e.g.
+// Pairs("field1", 1, "field2", 2).
+func (c *Cmd) Pairs(kv ...interface{}) *Cmd {
+	if len(kv)%2 != 0 {
+		if c.err == nil {
+			c.err = ErrOddNumberOfPairs
+		}
+		return c
+	}
+	return c.Arg(kv...)
+}
+
+// EX appends the EX option with the given expiration time, in seconds. The
+// time is rounded up, so a key never expires earlier than asked for.
+func (c *Cmd) EX(d time.Duration) *Cmd {
+	return c.Opt("EX", roundUp(d, time.Second))
+}
+
+// PX appends the PX option with the given expiration time, in milliseconds.
+// The time is rounded up, like with EX.
+func (c *Cmd) PX(d time.Duration) *Cmd {
+	return c.Opt("PX", roundUp(d, time.Millisecond))
+}
+
+// Returns d in the given unit, rounding positive values up so they don't
+// become zero.
+func roundUp(d, unit time.Duration) int64 {
+	n := int64(d / unit)
+	if d%unit > 0 {
+		n++
+	}
+	return n
+}
+
+// NX appends the NX flag.
+func (c *Cmd) NX() *Cmd {
+	return c.Flag("NX", true)
+}
+
+// XX appends the XX flag.
+func (c *Cmd) XX() *Cmd {
+	return c.Flag("XX", true)
+}
+
+// Name returns the name of the command.
+func (c *Cmd) Name() string {
+	return string(c.args[0])
+}
+
+// Args returns the name and the arguments of the command.
+func (c *Cmd) Args() [][]byte {
+	return c.args
+}
+
+// Err returns the first error found while appending arguments.
+func (c *Cmd) Err() error {
+	return c.err
+}
+
+func argToBytes(v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case string:
+		return []byte(t), nil
+	case []byte:
+		return t, nil
+	case int:
+		return strconv.AppendInt(nil, int64(t), 10), nil
+	case int8:
+		return strconv.AppendInt(nil, int64(t), 10), nil
+	case int16:
+		return strconv.AppendInt(nil, int64(t), 10), nil
+	case int32:
+		return strconv.AppendInt(nil, int64(t), 10), nil
+	case int64:
+		return strconv.AppendInt(nil, t, 10), nil
+	case uint:
+		return strconv.AppendUint(nil, uint64(t), 10), nil
+	case uint8:
+		return strconv.AppendUint(nil, uint64(t), 10), nil
+	case uint16:
+		return strconv.AppendUint(nil, uint64(t), 10), nil
+	case uint32:
+		return strconv.AppendUint(nil, uint64(t), 10), nil
+	case uint64:
+		return strconv.AppendUint(nil, t, 10), nil
+	case float32:
+		return floatToBytes(float64(t), 32), nil
+	case float64:
+		return floatToBytes(t, 64), nil
+	case bool:
+		if t {
+			return []byte("1"), nil
+		}
+		return []byte("0"), nil
+	case time.Duration:
+		return strconv.AppendFloat(nil, t.Seconds(), 'f', -1, 64), nil
+	case fmt.Stringer:
+		return []byte(t.String()), nil
+	}
+	return nil, fmt.Errorf(ErrUnsupportedArgument.Error(), v)
+}
+
+func floatToBytes(f float64, bitSize int) []byte {
+	switch {
+	case math.IsInf(f, 1):
+		return []byte("+inf")
+	case math.IsInf(f, -1):
+		return []byte("-inf")
+	}
+	return strconv.AppendFloat(nil, f, 'f', -1, bitSize)
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2015 José Carlos Nieto, https://menteslibres.net/xiam
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the
+// "Software"), to deal in the Software without restriction, including
+// without limitation the rights to use, copy, modify, merge, publish,
+// distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to
+// the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+// LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+// WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package resp
+
+import (
+	"strings"
+)
+
+// CommandInfo describes a command, as returned by COMMAND and COMMAND INFO.
+type CommandInfo struct {
+	Name     string
+	Arity    int
+	Flags    []string
+	FirstKey int
+	LastKey  int
+	Step     int
+}
+
+// UnmarshalRESP decodes a [name, arity, [flag, ...], first key, last key,
+// step, ...] array. The elements that redis 7 added after step are ignored.
+func (c *CommandInfo) UnmarshalRESP(m *Message) error {
+	*c = CommandInfo{}
+
+	a, err := m.Slice()
+	if err != nil {
+		return err
+	}
+
+	if len(a) < 6 {
+		return ErrInvalidInput
+	}
+
+	if c.Name, err = a[0].Str(); err != nil {
+		return err
+	}
+
+	var n [4]int64
+	for i, j := range []int{1, 3, 4, 5} {
+		if n[i], err = a[j].Int64(); err != nil {
+			return err
+		}
+	}
+	c.Arity, c.FirstKey, c.LastKey, c.Step = int(n[0]), int(n[1]), int(n[2]), int(n[3])
+
+	var flags []*Message
+	if flags, err = a[2].Slice(); err != nil {
+		return err
+	}
+
+	c.Flags = make([]string, len(flags))
+	for i := range flags {
+		if c.Flags[i], err = flags[i].Str(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HasFlag reports whether the command has the given flag, like "readonly" or
+// "write".
+func (c *CommandInfo) HasFlag(flag string) bool {
+	for i := range c.Flags {
+		if c.Flags[i] == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// CommandTable maps lowercase command names to their descriptions. It can be
+// used to tell reads, which may be sent to replicas, from writes.
+type CommandTable map[string]CommandInfo
+
+// LoadCommands asks the server for the description of all its commands with
+// COMMAND, writing the command to e and reading the reply from d.
+func LoadCommands(e *Encoder, d *Decoder) (CommandTable, error) {
+	if err := e.Encode(NewCmd("COMMAND")); err != nil {
+		return nil, err
+	}
+
+	var commands []CommandInfo
+	if err := d.Decode(&commands); err != nil {
+		return nil, err
+	}
+
+	t := make(CommandTable, len(commands))
+	for i := range commands {
+		t[strings.ToLower(commands[i].Name)] = commands[i]
+	}
+
+	return t, nil
+}
+
+// ReadOnly reports whether c is known to only read data. Commands that are not
+// in the table are not read-only.
+func (t CommandTable) ReadOnly(c *Cmd) bool {
+	info, ok := t[strings.ToLower(c.Name())]
+	return ok && info.HasFlag("readonly")
+}
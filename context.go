@@ -0,0 +1,147 @@
+// Copyright (c) 2015 José Carlos Nieto, https://menteslibres.net/xiam
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the
+// "Software"), to deal in the Software without restriction, including
+// without limitation the rights to use, copy, modify, merge, publish,
+// distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to
+// the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+// LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+// WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package resp
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// A deadline in the past, used to unblock pending reads and writes.
+var aLongTimeAgo = time.Unix(1, 0)
+
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// Sets the deadline of ctx on a stream and watches ctx to unblock the stream
+// once ctx is done. The returned function must be called when the operation on
+// the stream is over, it stops watching ctx and clears the deadline.
+func watchContext(ctx context.Context, setDeadline func(time.Time) error) func() {
+	if deadline, ok := ctx.Deadline(); ok {
+		setDeadline(deadline)
+	}
+
+	if ctx.Done() == nil {
+		return func() {
+			setDeadline(time.Time{})
+		}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			setDeadline(aLongTimeAgo)
+		case <-stop:
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+		setDeadline(time.Time{})
+	}
+}
+
+// Returns ctx.Err() if err was caused by ctx. The deadline of ctx may expire on
+// the stream slightly before ctx is done, so timeouts that happen after the
+// deadline are reported as context.DeadlineExceeded.
+func contextError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+			return context.DeadlineExceeded
+		}
+	}
+
+	return err
+}
+
+// DecodeContext is like Decode, but if the input stream has a
+// SetReadDeadline method (like net.Conn) the deadline of ctx is applied to
+// reads and a read blocked on the stream is interrupted once ctx is done. In
+// that case ctx.Err() is returned. The read deadline of the stream is cleared
+// after DecodeContext returns.
+//
+// If the decoder was interrupted while waiting for the beginning of a message
+// it can be used again, otherwise it is poisoned (see Poisoned).
+//
+// If the input stream has no SetReadDeadline method DecodeContext can't be
+// interrupted and only checks ctx before decoding.
+func (d *Decoder) DecodeContext(ctx context.Context, v interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	rd, ok := d.src.(readDeadliner)
+	if !ok {
+		return d.Decode(v)
+	}
+
+	stop := watchContext(ctx, rd.SetReadDeadline)
+	err := d.Decode(v)
+	stop()
+
+	return contextError(ctx, err)
+}
+
+// EncodeContext is like Encode, but if the output stream has a
+// SetWriteDeadline method (like net.Conn) the deadline of ctx is applied to
+// writes and a write blocked on the stream is interrupted once ctx is done. In
+// that case ctx.Err() is returned and the stream may hold a partially written
+// message. The write deadline of the stream is cleared after EncodeContext
+// returns.
+//
+// If the output stream has no SetWriteDeadline method EncodeContext can't be
+// interrupted and only checks ctx before encoding.
+func (e *Encoder) EncodeContext(ctx context.Context, v interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	wd, ok := e.w.(writeDeadliner)
+	if !ok {
+		return e.Encode(v)
+	}
+
+	stop := watchContext(ctx, wd.SetWriteDeadline)
+	err := e.Encode(v)
+	stop()
+
+	return contextError(ctx, err)
+}
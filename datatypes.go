@@ -0,0 +1,267 @@
+// Copyright (c) 2015 José Carlos Nieto, https://menteslibres.net/xiam
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the
+// "Software"), to deal in the Software without restriction, including
+// without limitation the rights to use, copy, modify, merge, publish,
+// distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to
+// the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+// LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+// WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package resp
+
+import (
+	"time"
+)
+
+// Do writes c to e and reads its reply from d into the value pointed to by v,
+// applying the same conversions as Unmarshal. Error replies are returned as
+// errors, see ErrorCode. If v is nil the reply is discarded.
+func Do(e *Encoder, d *Decoder, c *Cmd, v interface{}) error {
+	var reply Message
+	if err := roundTrip(e, d, c, &reply); err != nil {
+		return err
+	}
+
+	if reply.Type == ErrorHeader {
+		return replyError(&reply)
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return messageToValue(&reply, v)
+}
+
+// Get returns the value of a key with GET. ErrMessageIsNil is returned if the
+// key does not exist.
+func Get(e *Encoder, d *Decoder, key string) (string, error) {
+	var s string
+	err := Do(e, d, NewCmd("GET", key), &s)
+	return s, err
+}
+
+// SetArgs holds the options of SET. Zero values are not sent.
+type SetArgs struct {
+	// TTL is sent with PX, so it is rounded up to milliseconds.
+	TTL     time.Duration
+	KeepTTL bool
+	NX      bool
+	XX      bool
+}
+
+// Set sets the value of a key with SET. It returns false if the value was not
+// set because of the NX or XX options.
+func Set(e *Encoder, d *Decoder, key string, value interface{}, a SetArgs) (bool, error) {
+	c := NewCmd("SET", key, value)
+	if a.TTL > 0 {
+		c.PX(a.TTL)
+	}
+	c.Flag("KEEPTTL", a.KeepTTL)
+	c.Flag("NX", a.NX)
+	c.Flag("XX", a.XX)
+
+	var reply Message
+	if err := Do(e, d, c, &reply); err != nil {
+		return false, err
+	}
+
+	return !reply.IsNil, nil
+}
+
+// IncrBy increments the number stored at a key with INCRBY and returns the new
+// value.
+func IncrBy(e *Encoder, d *Decoder, key string, n int64) (int64, error) {
+	var v int64
+	err := Do(e, d, NewCmd("INCRBY", key, n), &v)
+	return v, err
+}
+
+// Del removes keys with DEL and returns the number of keys that were removed.
+func Del(e *Encoder, d *Decoder, keys ...string) (int64, error) {
+	return keysInt(e, d, "DEL", keys)
+}
+
+// Exists returns the number of the given keys that exist, with EXISTS.
+func Exists(e *Encoder, d *Decoder, keys ...string) (int64, error) {
+	return keysInt(e, d, "EXISTS", keys)
+}
+
+func keysInt(e *Encoder, d *Decoder, name string, keys []string) (int64, error) {
+	c := NewCmd(name)
+	for i := range keys {
+		c.Arg(keys[i])
+	}
+
+	var n int64
+	err := Do(e, d, c, &n)
+	return n, err
+}
+
+// Expire sets the time to live of a key with PEXPIRE, rounded up to
+// milliseconds. It returns false if the key does not exist.
+func Expire(e *Encoder, d *Decoder, key string, ttl time.Duration) (bool, error) {
+	var ok bool
+	err := Do(e, d, NewCmd("PEXPIRE", key, roundUp(ttl, time.Millisecond)), &ok)
+	return ok, err
+}
+
+// Values returned by TTL for keys that have no time to live and keys that
+// don't exist.
+const (
+	NoExpiration time.Duration = -1
+	KeyNotFound  time.Duration = -2
+)
+
+// TTL returns the time to live of a key with PTTL, or NoExpiration or
+// KeyNotFound.
+func TTL(e *Encoder, d *Decoder, key string) (time.Duration, error) {
+	var ms int64
+	if err := Do(e, d, NewCmd("PTTL", key), &ms); err != nil {
+		return 0, err
+	}
+
+	if ms < 0 {
+		return time.Duration(ms), nil
+	}
+
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// HGet returns the value of a field of a hash with HGET. ErrMessageIsNil is
+// returned if the field does not exist.
+func HGet(e *Encoder, d *Decoder, key, field string) (string, error) {
+	var s string
+	err := Do(e, d, NewCmd("HGET", key, field), &s)
+	return s, err
+}
+
+// HSet sets fields of a hash with HSET, e.g. HSet(e, d, key, "field1", 1,
+// "field2", 2), and returns the number of fields that were added.
+func HSet(e *Encoder, d *Decoder, key string, fieldValues ...interface{}) (int64, error) {
+	var n int64
+	err := Do(e, d, NewCmd("HSET", key).Pairs(fieldValues...), &n)
+	return n, err
+}
+
+// HGetAll returns all the fields and values of a hash with HGETALL.
+func HGetAll(e *Encoder, d *Decoder, key string) (map[string]string, error) {
+	var reply Message
+	if err := Do(e, d, NewCmd("HGETALL", key), &reply); err != nil {
+		return nil, err
+	}
+
+	kv, err := reply.Map()
+	if err != nil {
+		return nil, err
+	}
+
+	h := make(map[string]string, len(kv))
+	for k := range kv {
+		if h[k], err = kv[k].Str(); err != nil {
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
+// LPush prepends values to a list with LPUSH and returns the length of the
+// list.
+func LPush(e *Encoder, d *Decoder, key string, values ...interface{}) (int64, error) {
+	var n int64
+	err := Do(e, d, NewCmd("LPUSH", key).Arg(values...), &n)
+	return n, err
+}
+
+// RPush appends values to a list with RPUSH and returns the length of the
+// list.
+func RPush(e *Encoder, d *Decoder, key string, values ...interface{}) (int64, error) {
+	var n int64
+	err := Do(e, d, NewCmd("RPUSH", key).Arg(values...), &n)
+	return n, err
+}
+
+// LRange returns the elements of a list between start and stop (inclusive)
+// with LRANGE.
+func LRange(e *Encoder, d *Decoder, key string, start, stop int64) ([]string, error) {
+	var values []string
+	err := Do(e, d, NewCmd("LRANGE", key, start, stop), &values)
+	return values, err
+}
+
+// SAdd adds members to a set with SADD and returns the number of members that
+// were added.
+func SAdd(e *Encoder, d *Decoder, key string, members ...interface{}) (int64, error) {
+	var n int64
+	err := Do(e, d, NewCmd("SADD", key).Arg(members...), &n)
+	return n, err
+}
+
+// SMembers returns the members of a set with SMEMBERS.
+func SMembers(e *Encoder, d *Decoder, key string) ([]string, error) {
+	var members []string
+	err := Do(e, d, NewCmd("SMEMBERS", key), &members)
+	return members, err
+}
+
+// Z is a member of a sorted set and its score.
+type Z struct {
+	Member string
+	Score  float64
+}
+
+// ZAdd adds members to a sorted set with ZADD and returns the number of
+// members that were added.
+func ZAdd(e *Encoder, d *Decoder, key string, members ...Z) (int64, error) {
+	c := NewCmd("ZADD", key)
+	for i := range members {
+		c.Arg(members[i].Score, members[i].Member)
+	}
+
+	var n int64
+	err := Do(e, d, c, &n)
+	return n, err
+}
+
+// ZRangeWithScores returns the members of a sorted set between start and stop
+// (inclusive) and their scores, with ZRANGE ... WITHSCORES.
+func ZRangeWithScores(e *Encoder, d *Decoder, key string, start, stop int64) ([]Z, error) {
+	var reply Message
+	if err := Do(e, d, NewCmd("ZRANGE", key, start, stop, "WITHSCORES"), &reply); err != nil {
+		return nil, err
+	}
+
+	a, err := reply.Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(a)%2 != 0 {
+		return nil, ErrInvalidInput
+	}
+
+	z := make([]Z, len(a)/2)
+	for i := range z {
+		if z[i].Member, err = a[2*i].Str(); err != nil {
+			return nil, err
+		}
+		if z[i].Score, err = a[2*i+1].Float64(); err != nil {
+			return nil, err
+		}
+	}
+
+	return z, nil
+}
@@ -24,31 +24,69 @@ package resp
 import (
 	"errors"
 	"io"
-	"reflect"
-	"strconv"
 )
 
+// Initial size of the buffer used in zero-copy mode.
+const minSharedBufferSize = 512
+
 // Decoder reads and decodes RESP objects from an input stream.
 type Decoder struct {
-	r *Reader
+	r   *Reader
+	src io.Reader
+
+	zeroCopy bool
+	buf      []byte
+
+	// State of the tokenizer, see Token.
+	arrays       []int
+	pendingBulk  int
+	arrayStarted bool
+
+	// Error that left the decoder in the middle of a message.
+	err error
+	// Whether the decoder is waiting for the beginning of a message.
+	idle bool
 }
 
 // NewDecoder creates and returns a Decoder.
 func NewDecoder(r io.Reader) *Decoder {
 	d := &Decoder{
-		r: NewReader(r),
+		r:           NewReader(r),
+		src:         r,
+		pendingBulk: -1,
 	}
 	return d
 }
 
-// Attempts to decode the next message.
+// Attempts to decode the next message. Any space that was already allocated
+// by out (and its children) is reused.
 func (d *Decoder) next(out *Message) (err error) {
+	out.Error = nil
+	out.Integer = 0
+	out.Status = ""
+	out.IsNil = false
+	out.Array = out.Array[:0]
+
+	// Slices of the zero-copy buffer may still be in use, they are never
+	// reused to hold other messages.
+	if out.shared {
+		out.Bytes = nil
+		out.shared = false
+	} else {
+		out.Bytes = out.Bytes[:0]
+	}
+
 	// After the header, we expect a message ending with \r\n.
 	var line []byte
 	if out.Type, line, err = d.r.ReadLine(); err != nil {
 		return err
 	}
 
+	return d.nextBody(out, line)
+}
+
+// Decodes the rest of a message whose header line was already read.
+func (d *Decoder) nextBody(out *Message, line []byte) (err error) {
 	switch out.Type {
 
 	case StringHeader:
@@ -60,7 +98,7 @@ func (d *Decoder) next(out *Message) (err error) {
 		return
 
 	case IntegerHeader:
-		if out.Integer, err = strconv.ParseInt(string(line), 10, 64); err != nil {
+		if out.Integer, err = parseInt(line); err != nil {
 			return err
 		}
 		return
@@ -69,7 +107,7 @@ func (d *Decoder) next(out *Message) (err error) {
 		// Getting string length.
 		var msgLen int
 
-		if msgLen, err = strconv.Atoi(string(line)); err != nil {
+		if msgLen, err = parseLength(line); err != nil {
 			return
 		}
 
@@ -85,8 +123,11 @@ func (d *Decoder) next(out *Message) (err error) {
 			return
 		}
 
-		if out.Bytes, err = d.r.ReadMessageBytes(msgLen); err != nil {
-			return
+		if d.zeroCopy {
+			out.Bytes, err = d.readShared(msgLen)
+			out.shared = true
+		} else {
+			out.Bytes, err = d.r.readMessageBytes(out.Bytes, msgLen)
 		}
 
 		return
@@ -94,7 +135,7 @@ func (d *Decoder) next(out *Message) (err error) {
 		// Getting string length.
 		var arrLen int
 
-		if arrLen, err = strconv.Atoi(string(line)); err != nil {
+		if arrLen, err = parseLength(line); err != nil {
 			return
 		}
 
@@ -106,11 +147,19 @@ func (d *Decoder) next(out *Message) (err error) {
 			return
 		}
 
-		out.Array = make([]*Message, arrLen)
+		if cap(out.Array) < arrLen {
+			out.Array = append(out.Array[:cap(out.Array)], make([]*Message, arrLen-cap(out.Array))...)
+		}
+		out.Array = out.Array[:arrLen]
 
 		for i := 0; i < arrLen; i++ {
-			out.Array[i] = new(Message)
+			if out.Array[i] == nil {
+				out.Array[i] = new(Message)
+			}
 			if err = d.next(out.Array[i]); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
 				return err
 			}
 		}
@@ -121,29 +170,262 @@ func (d *Decoder) next(out *Message) (err error) {
 	return ErrInvalidInput
 }
 
-// Decode attempts to decode the whole message in buffer.
-func (d *Decoder) Decode(v interface{}) (err error) {
-	out := new(Message)
+// Appends the exact bytes of the next message to dst. The structure of the
+// message is validated but no Message values are created.
+func (d *Decoder) nextRaw(dst []byte) ([]byte, error) {
+	lineType, line, err := d.r.ReadLine()
+	if err != nil {
+		return dst, err
+	}
 
-	if err = d.next(out); err != nil {
+	dst = append(dst, lineType)
+	dst = append(dst, line...)
+	dst = append(dst, endOfLine...)
+
+	switch lineType {
+
+	case StringHeader, ErrorHeader:
+		return dst, nil
+
+	case IntegerHeader:
+		if _, err = parseInt(line); err != nil {
+			return dst, err
+		}
+		return dst, nil
+
+	case BulkHeader:
+		var msgLen int
+
+		if msgLen, err = parseLength(line); err != nil {
+			return dst, err
+		}
+
+		if msgLen > bulkMessageMaxLength {
+			return dst, ErrMessageIsTooLarge
+		}
+
+		if msgLen < 0 {
+			return dst, nil
+		}
+
+		var buf []byte
+		if buf, err = d.r.ReadMessageBytes(msgLen); err != nil {
+			return dst, err
+		}
+
+		dst = append(dst, buf...)
+		dst = append(dst, endOfLine...)
+
+		return dst, nil
+
+	case ArrayHeader:
+		var arrLen int
+
+		if arrLen, err = parseLength(line); err != nil {
+			return dst, err
+		}
+
+		for i := 0; i < arrLen; i++ {
+			if dst, err = d.nextRaw(dst); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return dst, err
+			}
+		}
+
+		return dst, nil
+	}
+
+	return dst, ErrInvalidInput
+}
+
+// Reads a bulk message of length n into the buffer that is shared by all the
+// messages decoded in zero-copy mode.
+func (d *Decoder) readShared(n int) ([]byte, error) {
+	if cap(d.buf)-len(d.buf) < n {
+		// Slices that were already returned keep pointing to the old buffer.
+		size := 2 * cap(d.buf)
+		if size < minSharedBufferSize {
+			size = minSharedBufferSize
+		}
+		if size < n {
+			size = n
+		}
+		d.buf = make([]byte, 0, size)
+	}
+
+	start := len(d.buf)
+
+	buf, err := d.r.readMessageBytes(d.buf[start:start:start+n], n)
+	if err != nil {
+		return nil, err
+	}
+
+	d.buf = d.buf[:start+n]
+
+	return buf, nil
+}
+
+// Marks the decoder as poisoned if err left it in the middle of a message.
+// Errors that happen while waiting for the beginning of a message, like
+// timeouts, don't poison the decoder, unless the input stream ends before the
+// last array is complete.
+func (d *Decoder) fail(err error) error {
+	idle := d.idle
+	d.idle = false
+
+	if err == nil {
+		return nil
+	}
+
+	if err == io.EOF {
+		if len(d.arrays) == 0 {
+			return err
+		}
+		err = io.ErrUnexpectedEOF
+	} else if idle {
 		return err
 	}
 
-	if v == nil {
+	d.err = err
+	return err
+}
+
+// Poisoned reports whether the decoder found an error in the middle of a
+// message, like malformed input or an input stream that was closed too soon.
+// Subsequent calls to the decoder return ErrDecoderPoisoned, and since there's
+// no way to know where the next message begins the underlying connection
+// should be dropped. See Resync for tools that need to keep reading.
+func (d *Decoder) Poisoned() bool {
+	return d.err != nil
+}
+
+// Resync recovers a poisoned decoder by discarding input until the next line
+// that looks like the beginning of a message, and returns the number of
+// discarded bytes. Any partially read array is abandoned. There's no guarantee
+// that the line actually is the beginning of a message, so this is only
+// intended for tools that inspect traffic and prefer to keep reading over
+// failing.
+func (d *Decoder) Resync() (int, error) {
+	d.err = nil
+	d.arrays = d.arrays[:0]
+	d.pendingBulk = -1
+	d.arrayStarted = false
+
+	n, err := d.r.resync()
+	if err != nil && err != io.EOF {
+		d.err = err
+	}
+	return n, err
+}
+
+// SetMaxLineLength sets the maximum length of a line, including its header and
+// EOL marker. Decoding a message with a longer line fails with ErrLineTooLong.
+// A value of zero or less disables the limit.
+func (d *Decoder) SetMaxLineLength(n int) {
+	d.r.SetMaxLineLength(n)
+}
+
+// SetZeroCopy enables or disables zero-copy mode. In zero-copy mode the
+// contents of bulk messages are not copied into new slices but are returned as
+// sub-slices of a buffer owned by the decoder. Similar to bufio.Scanner.Bytes,
+// these slices are only valid until the next call to Decode, DecodeMessage or
+// DecodeBulk, which may overwrite them.
+func (d *Decoder) SetZeroCopy(enabled bool) {
+	d.zeroCopy = enabled
+}
+
+// DecodeBulk decodes the next message, which must be a bulk message, and
+// stores its contents into dst. If dst is not large enough to hold the
+// contents a new slice is allocated. DecodeBulk returns the slice holding the
+// contents of the message, or ErrMessageIsNil if the message was nil.
+func (d *Decoder) DecodeBulk(dst []byte) ([]byte, error) {
+	if d.err != nil {
+		return nil, ErrDecoderPoisoned
+	}
+
+	if err := d.begin(); err != nil {
+		return nil, d.fail(err)
+	}
+
+	lineType, line, err := d.r.ReadLine()
+	if err != nil {
+		return nil, d.fail(err)
+	}
+
+	if lineType == BulkHeader {
+		var msgLen int
+
+		if msgLen, err = parseLength(line); err != nil {
+			return nil, d.fail(err)
+		}
+
+		if msgLen > bulkMessageMaxLength {
+			return nil, d.fail(ErrMessageIsTooLarge)
+		}
+
+		if msgLen < 0 {
+			return nil, ErrMessageIsNil
+		}
+
+		if dst, err = d.r.readMessageBytes(dst, msgLen); err != nil {
+			return nil, d.fail(err)
+		}
+
+		return dst, nil
+	}
+
+	// Not a bulk message, the rest of the message has to be consumed anyway.
+	out := &Message{Type: lineType}
+	if err = d.nextBody(out, line); err != nil {
+		return nil, d.fail(err)
+	}
+
+	return out.BytesValue()
+}
+
+// DecodeMessage decodes the next message into m. Unlike Decode, DecodeMessage
+// reuses the elements and buffers that m already holds from previous calls, so
+// the contents of m (including any []byte values taken from it) are only valid
+// until the next call to DecodeMessage with the same m.
+func (d *Decoder) DecodeMessage(m *Message) error {
+	if m == nil {
 		return ErrExpectingDestination
 	}
+	if d.err != nil {
+		return ErrDecoderPoisoned
+	}
+	if err := d.begin(); err != nil {
+		return d.fail(err)
+	}
+	return d.fail(d.next(m))
+}
 
-	dst := reflect.ValueOf(v)
+// Decode attempts to decode the whole message in buffer.
+func (d *Decoder) Decode(v interface{}) (err error) {
+	if d.err != nil {
+		return ErrDecoderPoisoned
+	}
 
-	if dst.Kind() != reflect.Ptr || dst.IsNil() {
-		return ErrExpectingPointer
+	if err = d.begin(); err != nil {
+		return d.fail(err)
 	}
 
-	if err = redisMessageToType(dst.Elem(), out); err != nil {
-		if out.Type == ErrorHeader {
-			return errors.New(out.Error.Error())
+	if raw, ok := v.(*RawMessage); ok && raw != nil {
+		var buf []byte
+		if buf, err = d.nextRaw((*raw)[:0]); err != nil {
+			return d.fail(err)
 		}
+		*raw = buf
+		return nil
 	}
 
-	return err
+	out := new(Message)
+
+	if err = d.next(out); err != nil {
+		return d.fail(err)
+	}
+
+	return messageToValue(out, v)
 }
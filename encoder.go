@@ -185,9 +185,12 @@ func (e *Encoder) writeEncoded(w io.Writer, data interface{}) (err error) {
 
 		if w != nil {
 			e.mu.Lock()
-			w.Write(e.buf)
+			_, err = w.Write(e.buf)
 			e.buf = []byte{}
 			e.mu.Unlock()
+			if err != nil {
+				return err
+			}
 		}
 
 		for i := range v {
@@ -198,6 +201,16 @@ func (e *Encoder) writeEncoded(w io.Writer, data interface{}) (err error) {
 
 		return nil
 
+	case RawMessage:
+		b = make([]byte, 0, len(v))
+		b = append(b, v...)
+
+	case *Cmd:
+		if v.err != nil {
+			return v.err
+		}
+		return e.writeEncoded(w, v.args)
+
 	case *Message:
 		switch v.Type {
 		case ErrorHeader:
@@ -226,10 +239,10 @@ func (e *Encoder) writeEncoded(w io.Writer, data interface{}) (err error) {
 
 	if w != nil {
 		e.mu.Lock()
-		w.Write(e.buf)
+		_, err = w.Write(e.buf)
 		e.buf = []byte{}
 		e.mu.Unlock()
 	}
 
-	return nil
+	return err
 }
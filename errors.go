@@ -33,6 +33,18 @@ var (
 	// that is considered too large.
 	ErrMessageIsTooLarge = errors.New(`resp: Message is too large`)
 
+	// ErrLineTooLong is returned when a line is longer than the maximum line
+	// length of a Reader.
+	ErrLineTooLong = errors.New(`resp: Line is too long`)
+
+	// ErrNoBulkToken is returned when the user attempts to read the contents of
+	// a bulk message that was not returned by Decoder.Token.
+	ErrNoBulkToken = errors.New(`resp: Last token was not a bulk message`)
+
+	// ErrDecoderPoisoned is returned by a decoder that previously found an error
+	// in the middle of a message.
+	ErrDecoderPoisoned = errors.New(`resp: Decoder stopped after an error in the middle of a message`)
+
 	// ErrMissingMessageHeader is returned when the user attempts to encode a
 	// message that has no header.
 	ErrMissingMessageHeader = errors.New(`resp: Missing message header`)
@@ -45,6 +57,14 @@ var (
 	// value into an incompatible destination type.
 	ErrUnsupportedConversion = errors.New(`resp: Unsupported conversion: %s to %s`)
 
+	// ErrUnsupportedArgument is returned when the user attempts to add an
+	// argument of an unsupported type to a command.
+	ErrUnsupportedArgument = errors.New(`resp: Unsupported argument type: %T`)
+
+	// ErrOddNumberOfPairs is returned when the user attempts to add a list of
+	// key/value pairs that has an odd number of elements to a command.
+	ErrOddNumberOfPairs = errors.New(`resp: Expecting an even number of elements for key/value pairs`)
+
 	// ErrMessageIsNil is returned when an user attempts to encode a nil message.
 	ErrMessageIsNil = errors.New(`resp: Message is nil`)
 
@@ -55,4 +75,46 @@ var (
 	// ErrExpectingDestination is returned when a user attempts to unmarshal into
 	// a nil value.
 	ErrExpectingDestination = errors.New(`resp: Expecting a valid destination, but a nil value was provided`)
+
+	// ErrUnsupportedProtocol is returned when the user asks for a version of
+	// the protocol other than RESP2.
+	ErrUnsupportedProtocol = errors.New(`resp: Unsupported protocol version: %d`)
+
+	// ErrUnknownMaster is returned when a sentinel does not know the requested
+	// master.
+	ErrUnknownMaster = errors.New(`resp: Unknown master: %s`)
+
+	// ErrBlockTimeout is returned when a blocking command like BLPOP timed out
+	// on the server.
+	ErrBlockTimeout = errors.New(`resp: Blocking command timed out`)
+
+	// ErrPathNotFound is returned when a path given to Message.Get() does not
+	// match any element of the message.
+	ErrPathNotFound = errors.New(`resp: Path not found: %v`)
+
+	// ErrInvalidPath is returned when a path given to Message.Get() contains an
+	// element that is neither an int nor a string.
+	ErrInvalidPath = errors.New(`resp: Path elements must be either int or string values`)
 )
+
+// ErrorCode returns the code that prefixes an error message sent by redis, like
+// "ERR", "WRONGTYPE" or "NOSCRIPT". An empty string is returned if the message
+// has no code.
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	s := err.Error()
+
+	i := 0
+	for i < len(s) && s[i] >= 'A' && s[i] <= 'Z' {
+		i++
+	}
+
+	if i == 0 || (i < len(s) && s[i] != ' ') {
+		return ""
+	}
+
+	return s[:i]
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2015 José Carlos Nieto, https://menteslibres.net/xiam
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the
+// "Software"), to deal in the Software without restriction, including
+// without limitation the rights to use, copy, modify, merge, publish,
+// distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to
+// the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+// LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+// WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package resp
+
+import (
+	"fmt"
+)
+
+// ServerInfo describes the server on the other side of a connection, as
+// returned by HELLO.
+type ServerInfo struct {
+	Server  string
+	Version string
+	Proto   int
+	ID      int64
+	Mode    string
+	Role    string
+	Modules []string
+}
+
+// UnmarshalRESP decodes the flat [key, value, ...] array that HELLO returns
+// with RESP2. Unknown keys are ignored.
+func (s *ServerInfo) UnmarshalRESP(m *Message) error {
+	*s = ServerInfo{}
+
+	kv, err := m.Map()
+	if err != nil {
+		return err
+	}
+
+	for k, v := range kv {
+		switch k {
+		case "server":
+			s.Server, err = v.Str()
+		case "version":
+			s.Version, err = v.Str()
+		case "proto":
+			var n int64
+			n, err = v.Int64()
+			s.Proto = int(n)
+		case "id":
+			s.ID, err = v.Int64()
+		case "mode":
+			s.Mode, err = v.Str()
+		case "role":
+			s.Role, err = v.Str()
+		case "modules":
+			s.Modules, err = moduleNames(v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the names of the modules listed by HELLO, each module is a flat
+// [key, value, ...] array with a "name" key.
+func moduleNames(m *Message) ([]string, error) {
+	a, err := m.Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(a))
+	for i := range a {
+		var kv map[string]*Message
+		if kv, err = a[i].Map(); err != nil {
+			return nil, err
+		}
+		if name, ok := kv["name"]; ok {
+			var s string
+			if s, err = name.Str(); err != nil {
+				return nil, err
+			}
+			names = append(names, s)
+		}
+	}
+
+	return names, nil
+}
+
+// AuthError is returned when the server rejects the credentials of a
+// connection (WRONGPASS, or any error to AUTH on servers older than redis 6)
+// or requires credentials that were not given (NOAUTH).
+type AuthError struct {
+	Code    string
+	Message string
+}
+
+func (e *AuthError) Error() string {
+	return e.Message
+}
+
+// Returns the error of an error reply, as an *AuthError if it is about
+// authentication.
+func replyError(reply *Message) error {
+	switch code := ErrorCode(reply.Error); code {
+	case "WRONGPASS", "NOAUTH":
+		return &AuthError{Code: code, Message: reply.Error.Error()}
+	}
+	return reply.Error
+}
+
+// HelloArgs holds the options of a HELLO handshake. Proto defaults to 2, which
+// is the only protocol version this package speaks.
+type HelloArgs struct {
+	Proto      int
+	User       string
+	Password   string
+	ClientName string
+	DB         int
+
+	// OnConnect, if not nil, is called at the end of the handshake to run any
+	// other commands that every connection needs.
+	OnConnect func(e *Encoder, d *Decoder) error
+}
+
+// Cmd returns the HELLO command for a.
+func (a *HelloArgs) Cmd() *Cmd {
+	proto := a.Proto
+	if proto == 0 {
+		proto = 2
+	}
+
+	c := NewCmd("HELLO", proto)
+	if a.Password != "" {
+		user := a.User
+		if user == "" {
+			user = "default"
+		}
+		c.Arg("AUTH", user, a.Password)
+	}
+
+	if a.ClientName != "" {
+		c.Opt("SETNAME", a.ClientName)
+	}
+
+	return c
+}
+
+// Hello sends HELLO on a new connection, writing commands to e and reading
+// replies from d, and returns what the server said about itself. Servers older
+// than redis 6 reply to HELLO with an error, in that case the connection is
+// set up with AUTH and CLIENT SETNAME instead and the returned ServerInfo only
+// has its Proto field set. If a.DB is not zero the database is selected with
+// SELECT. Rejected credentials are reported as an *AuthError.
+//
+// RESP3 is not supported by the Encoder and Decoder, so asking for any
+// protocol version other than 2 fails before anything is sent.
+func Hello(e *Encoder, d *Decoder, a *HelloArgs) (*ServerInfo, error) {
+	if a.Proto != 0 && a.Proto != 2 {
+		return nil, fmt.Errorf(ErrUnsupportedProtocol.Error(), a.Proto)
+	}
+
+	var reply Message
+	if err := roundTrip(e, d, a.Cmd(), &reply); err != nil {
+		return nil, err
+	}
+
+	info := &ServerInfo{}
+
+	switch {
+	case reply.Type != ErrorHeader:
+		if err := info.UnmarshalRESP(&reply); err != nil {
+			return nil, err
+		}
+	case ErrorCode(reply.Error) == "ERR":
+		// HELLO is not known, falling back to AUTH.
+		info.Proto = 2
+		if a.Password != "" {
+			auth := NewCmd("AUTH")
+			if a.User != "" {
+				auth.Arg(a.User)
+			}
+			if err := roundTrip(e, d, auth.Arg(a.Password), &reply); err != nil {
+				return nil, err
+			}
+			if reply.Type == ErrorHeader {
+				// Servers older than redis 6 reject passwords with ERR.
+				return nil, &AuthError{Code: ErrorCode(reply.Error), Message: reply.Error.Error()}
+			}
+		}
+		if a.ClientName != "" {
+			if err := Do(e, d, NewCmd("CLIENT", "SETNAME", a.ClientName), nil); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, replyError(&reply)
+	}
+
+	if a.DB != 0 {
+		if err := Do(e, d, NewCmd("SELECT", a.DB), nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if a.OnConnect != nil {
+		if err := a.OnConnect(e, d); err != nil {
+			return nil, err
+		}
+	}
+
+	return info, nil
+}
+
+// Writes c to e and reads the reply from d into reply.
+func roundTrip(e *Encoder, d *Decoder, c *Cmd, reply *Message) error {
+	if err := e.Encode(c); err != nil {
+		return err
+	}
+	return d.DecodeMessage(reply)
+}
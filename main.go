@@ -45,6 +45,13 @@ const (
 	bulkMessageMaxLength = 512 * 1024
 )
 
+// Unmarshaler is the interface implemented by types that can unmarshal a RESP
+// message into themselves. Unmarshal and Decoder.Decode call UnmarshalRESP
+// instead of converting the message, this includes nil messages.
+type Unmarshaler interface {
+	UnmarshalRESP(m *Message) error
+}
+
 func byteToTypeName(c byte) string {
 	switch c {
 	case StringHeader:
@@ -82,8 +89,8 @@ func Marshal(v interface{}) ([]byte, error) {
 }
 
 // Unmarshal parses the RESP-encoded data and stores the result in the value
-// pointed to by v. At this moment, it only works with string, int, []byte and
-// []interface{} types.
+// pointed to by v. At this moment, it only works with string, int, []byte,
+// []interface{} and Unmarshaler types.
 func Unmarshal(data []byte, v interface{}) error {
 	var err error
 
@@ -101,6 +108,29 @@ func Unmarshal(data []byte, v interface{}) error {
 	return nil
 }
 
+// Stores out in the value pointed to by v. If out can't be converted and is an
+// error message, the error message is returned.
+func messageToValue(out *Message, v interface{}) error {
+	if v == nil {
+		return ErrExpectingDestination
+	}
+
+	dst := reflect.ValueOf(v)
+
+	if dst.Kind() != reflect.Ptr || dst.IsNil() {
+		return ErrExpectingPointer
+	}
+
+	err := redisMessageToType(dst.Elem(), out)
+	if err != nil {
+		if out.Type == ErrorHeader {
+			return errors.New(out.Error.Error())
+		}
+	}
+
+	return err
+}
+
 func redisMessageToType(dst reflect.Value, out *Message) error {
 
 	if dst.Type() == typeMessage {
@@ -108,6 +138,12 @@ func redisMessageToType(dst reflect.Value, out *Message) error {
 		return nil
 	}
 
+	if dst.CanAddr() {
+		if u, ok := dst.Addr().Interface().(Unmarshaler); ok {
+			return u.UnmarshalRESP(out)
+		}
+	}
+
 	if out.IsNil {
 		dst.Set(reflect.Zero(dst.Type()))
 		return ErrMessageIsNil
@@ -23,11 +23,17 @@ package resp
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
+	"math"
+	"net"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"testing/iotest"
+	"time"
 )
 
 var (
@@ -244,8 +250,8 @@ func TestDecodeOnLongStringsLazyReader(t *testing.T) {
 		}
 
 		if bytes.Equal(test.Bytes, []byte(longMessage)) == false {
-			t.Logf("Expected %d bytes: ", len(longMessage), []byte(longMessage))
-			t.Logf("Actual %d bytes: ", len(test.Bytes), test.Bytes)
+			t.Logf("Expected %d bytes: %q", len(longMessage), longMessage)
+			t.Logf("Actual %d bytes: %q", len(test.Bytes), test.Bytes)
 			t.Fatal(errTestFailed)
 		}
 	}
@@ -869,3 +875,2139 @@ func TestEncoderAndDecoder(t *testing.T) {
 		t.Fatal(errTestFailed)
 	}
 }
+
+func TestMessageAccessors(t *testing.T) {
+	var test Message
+	var err error
+
+	if err = Unmarshal([]byte("*6\r\n$3\r\nfoo\r\n:1\r\n$3\r\nbar\r\n$4\r\n1.25\r\n$3\r\nbaz\r\n$-1\r\n"), &test); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := test.Map()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m) != 3 {
+		t.Fatal(errTestFailed)
+	}
+
+	var i int64
+	if i, err = m["foo"].Int64(); err != nil {
+		t.Fatal(err)
+	}
+	if i != 1 {
+		t.Fatal(errTestFailed)
+	}
+
+	var b bool
+	if b, err = m["foo"].Bool(); err != nil {
+		t.Fatal(err)
+	}
+	if b != true {
+		t.Fatal(errTestFailed)
+	}
+
+	var s string
+	if s, err = m["foo"].Str(); err != nil {
+		t.Fatal(err)
+	}
+	if s != "1" {
+		t.Fatal(errTestFailed)
+	}
+
+	var f float64
+	if f, err = m["bar"].Float64(); err != nil {
+		t.Fatal(err)
+	}
+	if f != 1.25 {
+		t.Fatal(errTestFailed)
+	}
+
+	if _, err = m["bar"].Int64(); err == nil {
+		t.Fatal(errErrorExpected)
+	}
+
+	if _, err = m["baz"].Str(); err != ErrMessageIsNil {
+		t.Fatal(errErrorExpected)
+	}
+
+	var a []*Message
+	if a, err = test.Slice(); err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 6 {
+		t.Fatal(errTestFailed)
+	}
+
+	var buf []byte
+	if buf, err = a[0].BytesValue(); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "foo" {
+		t.Fatal(errTestFailed)
+	}
+
+	if _, err = a[1].BytesValue(); err == nil {
+		t.Fatal(errErrorExpected)
+	}
+
+	if _, err = a[0].Slice(); err == nil {
+		t.Fatal(errErrorExpected)
+	}
+
+	// Error replies are returned as errors.
+	if err = Unmarshal([]byte("-ERR wrong type\r\n"), &test); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = test.Int64(); err == nil || err.Error() != "ERR wrong type" {
+		t.Fatal(errErrorExpected)
+	}
+
+	// Error messages built by hand may hold a nil error.
+	var e Message
+	e.SetError(nil)
+
+	var str string
+	if str, err = e.Str(); err != nil || str != "" {
+		t.Fatal(errTestFailed)
+	}
+
+	e.SetError(errors.New("ERR failed"))
+	if str, err = e.Str(); err != nil || str != "ERR failed" {
+		t.Fatal(errTestFailed)
+	}
+}
+
+func TestMessageGet(t *testing.T) {
+	var test Message
+	var err error
+
+	// A reply similar to XINFO STREAM FULL.
+	encoded := []byte("*4\r\n$6\r\nlength\r\n:1\r\n$7\r\nentries\r\n*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$3\r\nfoo\r\n$3\r\nbar\r\n")
+
+	if err = Unmarshal(encoded, &test); err != nil {
+		t.Fatal(err)
+	}
+
+	var m *Message
+
+	if m, err = test.Get("length"); err != nil {
+		t.Fatal(err)
+	}
+	if m.Integer != 1 {
+		t.Fatal(errTestFailed)
+	}
+
+	if m, err = test.Get("entries", 0, 1, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if string(m.Bytes) != "bar" {
+		t.Fatal(errTestFailed)
+	}
+
+	if m, err = test.Get(); err != nil {
+		t.Fatal(err)
+	}
+	if m != &test {
+		t.Fatal(errTestFailed)
+	}
+
+	if _, err = test.Get("entries", 1); err == nil {
+		t.Fatal(errErrorExpected)
+	}
+
+	if _, err = test.Get("missing"); err == nil {
+		t.Fatal(errErrorExpected)
+	}
+
+	if _, err = test.Get("length", 0); err == nil {
+		t.Fatal(errErrorExpected)
+	}
+
+	if _, err = test.Get(1.5); err != ErrInvalidPath {
+		t.Fatal(errErrorExpected)
+	}
+}
+
+func TestRawMessage(t *testing.T) {
+	var err error
+
+	frames := []string{
+		"+OK\r\n",
+		"-ERR unknown\r\n",
+		":-42\r\n",
+		"$-1\r\n",
+		"$8\r\nfoo\r\nbar\r\n",
+		"*-1\r\n",
+		"*2\r\n*3\r\n:1\r\n:2\r\n:3\r\n*2\r\n+Foo\r\n$3\r\nBar\r\n",
+	}
+
+	var stream []byte
+	for i := range frames {
+		stream = append(stream, frames[i]...)
+	}
+
+	d := NewDecoder(iotest.HalfReader(bytes.NewReader(stream)))
+
+	out := bytes.NewBuffer(nil)
+	e := NewEncoder(out)
+
+	for i := range frames {
+		var raw RawMessage
+		if err = d.Decode(&raw); err != nil {
+			t.Fatal(err)
+		}
+		if string(raw) != frames[i] {
+			t.Fatalf("Expecting %q, got %q.", frames[i], raw)
+		}
+		if err = e.Encode(raw); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if bytes.Equal(out.Bytes(), stream) == false {
+		t.Fatal(errTestFailed)
+	}
+
+	// Invalid structure.
+	var raw RawMessage
+	if err = Unmarshal([]byte("*2\r\n:1\r\n$12\r\nSmall\r\n"), &raw); err == nil {
+		t.Fatal(errErrorExpected)
+	}
+}
+
+func TestDecodeMessageReuse(t *testing.T) {
+	var err error
+
+	stream := "*3\r\n$3\r\nfoo\r\n$3\r\nbar\r\n$3\r\nbaz\r\n" +
+		"*2\r\n:1\r\n$-1\r\n" +
+		"$6\r\nfoobar\r\n" +
+		"*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"
+
+	d := NewDecoder(bytes.NewBufferString(stream))
+
+	m := AcquireMessage()
+	defer ReleaseMessage(m)
+
+	if err = d.DecodeMessage(m); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.Array) != 3 || string(m.Array[2].Bytes) != "baz" {
+		t.Fatal(errTestFailed)
+	}
+
+	first := m.Array[0]
+
+	if err = d.DecodeMessage(m); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.Array) != 2 || m.Array[0].Integer != 1 || m.Array[1].IsNil != true {
+		t.Fatal(errTestFailed)
+	}
+
+	if m.Array[0] != first {
+		t.Fatal(errTestFailed)
+	}
+
+	if err = d.DecodeMessage(m); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Type != BulkHeader || len(m.Array) != 0 || string(m.Bytes) != "foobar" {
+		t.Fatal(errTestFailed)
+	}
+
+	if err = d.DecodeMessage(m); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.Array) != 3 || m.Array[0] != first || string(m.Array[2].Bytes) != "c" {
+		t.Fatal(errTestFailed)
+	}
+
+	if err = d.DecodeMessage(nil); err != ErrExpectingDestination {
+		t.Fatal(errErrorExpected)
+	}
+}
+
+func TestDecodeZeroCopy(t *testing.T) {
+	var err error
+
+	d := NewDecoder(bytes.NewBufferString("*2\r\n$3\r\nfoo\r\n$3\r\nbar\r\n$3\r\nbaz\r\n"))
+	d.SetZeroCopy(true)
+
+	var m Message
+	if err = d.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	foo, bar := m.Array[0].Bytes, m.Array[1].Bytes
+
+	if string(foo) != "foo" || string(bar) != "bar" {
+		t.Fatal(errTestFailed)
+	}
+
+	// Payloads must not be able to overwrite each other.
+	if cap(foo) != len(foo) {
+		t.Fatal(errTestFailed)
+	}
+
+	var baz []byte
+	if err = d.Decode(&baz); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(baz) != "baz" {
+		t.Fatal(errTestFailed)
+	}
+
+	// The buffer is reused by the next call.
+	if &foo[0] != &baz[0] {
+		t.Fatal(errTestFailed)
+	}
+}
+
+func TestDecodeZeroCopyReuse(t *testing.T) {
+	var err error
+
+	a := NewDecoder(bytes.NewBufferString("*2\r\n$3\r\nAAA\r\n$3\r\nBBB\r\n$3\r\nCCC\r\n"))
+	a.SetZeroCopy(true)
+
+	b := NewDecoder(bytes.NewBufferString("*2\r\n$3\r\nZZZ\r\n$3\r\nYYY\r\n$3\r\nXXX\r\n"))
+
+	m := AcquireMessage()
+	if err = a.DecodeMessage(m); err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := m.Array[0].Bytes, m.Array[1].Bytes
+
+	// A message filled in zero-copy mode and reused by another decoder must not
+	// write into the buffer of the first one.
+	if err = b.DecodeMessage(m); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(m.Array[0].Bytes) != "ZZZ" || string(m.Array[1].Bytes) != "YYY" {
+		t.Fatal(errTestFailed)
+	}
+
+	if string(first) != "AAA" || string(second) != "BBB" {
+		t.Fatalf("Expecting AAA BBB, got %s %s.", first, second)
+	}
+
+	ReleaseMessage(m)
+
+	m = AcquireMessage()
+	if err = a.DecodeMessage(m); err != nil {
+		t.Fatal(err)
+	}
+
+	third := m.Bytes
+
+	ReleaseMessage(m)
+
+	if m.Bytes != nil {
+		t.Fatal(errTestFailed)
+	}
+
+	if err = b.DecodeMessage(m); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(third) != "CCC" || string(m.Bytes) != "XXX" {
+		t.Fatal(errTestFailed)
+	}
+}
+
+func TestDecoderDecodeBulk(t *testing.T) {
+	var err error
+
+	d := NewDecoder(bytes.NewBufferString("$6\r\nfoobar\r\n$-1\r\n-ERR failed\r\n*2\r\n:1\r\n:2\r\n$11\r\nhello world\r\n"))
+
+	dst := make([]byte, 8)
+
+	var buf []byte
+	if buf, err = d.DecodeBulk(dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != "foobar" || &buf[0] != &dst[0] {
+		t.Fatal(errTestFailed)
+	}
+
+	if _, err = d.DecodeBulk(dst); err != ErrMessageIsNil {
+		t.Fatal(errErrorExpected)
+	}
+
+	if _, err = d.DecodeBulk(dst); err == nil || err.Error() != "ERR failed" {
+		t.Fatal(errErrorExpected)
+	}
+
+	if _, err = d.DecodeBulk(dst); err == nil {
+		t.Fatal(errErrorExpected)
+	}
+
+	// The array was consumed, dst is too short for the next message.
+	if buf, err = d.DecodeBulk(dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != "hello world" {
+		t.Fatal(errTestFailed)
+	}
+}
+
+func TestReaderLongLines(t *testing.T) {
+	var err error
+
+	long := "+" + string(bytes.Repeat([]byte("a\nb\r"), 2000)) + "\r\n"
+
+	r := NewReader(iotest.HalfReader(bytes.NewBufferString(long + ":1\r\n")))
+
+	var lineType byte
+	var line []byte
+
+	if lineType, line, err = r.ReadLine(); err != nil {
+		t.Fatal(err)
+	}
+
+	if lineType != StringHeader || string(line) != long[1:len(long)-2] {
+		t.Fatal(errTestFailed)
+	}
+
+	if lineType, line, err = r.ReadLine(); err != nil {
+		t.Fatal(err)
+	}
+
+	if lineType != IntegerHeader || string(line) != "1" {
+		t.Fatal(errTestFailed)
+	}
+
+	// Lines that exceed the maximum length.
+	r = NewReader(bytes.NewBufferString(long))
+	r.SetMaxLineLength(100)
+
+	if _, _, err = r.ReadLine(); err != ErrLineTooLong {
+		t.Fatal(errErrorExpected)
+	}
+
+	d := NewDecoder(bytes.NewBufferString("+" + string(bytes.Repeat([]byte("a"), 200)) + "\r\n"))
+	d.SetMaxLineLength(100)
+
+	var s string
+	if err = d.Decode(&s); err != ErrLineTooLong {
+		t.Fatal(errErrorExpected)
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	valid := map[string]int64{
+		"0":                    0,
+		"-0":                   0,
+		"+12":                  12,
+		"-123":                 -123,
+		"9223372036854775807":  9223372036854775807,
+		"-9223372036854775808": -9223372036854775808,
+	}
+
+	for s, expected := range valid {
+		n, err := parseInt([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != expected {
+			t.Fatalf("Expecting %d, got %d.", expected, n)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"-",
+		"12a",
+		"1.5",
+		"9223372036854775808",
+		"-9223372036854775809",
+		"99999999999999999999",
+	}
+
+	for _, s := range invalid {
+		if _, err := parseInt([]byte(s)); err == nil {
+			t.Fatalf("Expecting an error for %q.", s)
+		}
+	}
+}
+
+func TestDecoderToken(t *testing.T) {
+	var err error
+
+	stream := "*4\r\n+OK\r\n:-7\r\n*2\r\n$3\r\nfoo\r\n$-1\r\n*0\r\n-ERR failed\r\n"
+
+	d := NewDecoder(iotest.HalfReader(bytes.NewBufferString(stream)))
+
+	expected := []Token{
+		{Type: ArrayStartToken, Len: 4},
+		{Type: StatusToken, Bytes: []byte("OK")},
+		{Type: IntegerToken, Integer: -7},
+		{Type: ArrayStartToken, Len: 2},
+		{Type: BulkToken, Len: 3, Bytes: []byte("foo")},
+		{Type: NilToken},
+		{Type: ArrayEndToken},
+		{Type: ArrayStartToken, Len: 0},
+		{Type: ArrayEndToken},
+		{Type: ArrayEndToken},
+		{Type: ErrorToken, Bytes: []byte("ERR failed")},
+	}
+
+	for i := range expected {
+		var tok Token
+		if tok, err = d.Token(); err != nil {
+			t.Fatal(err)
+		}
+		if tok.Type == BulkToken {
+			if tok.Bytes, err = d.BulkBytes(nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if tok.Type != expected[i].Type || tok.Len != expected[i].Len || tok.Integer != expected[i].Integer || !bytes.Equal(tok.Bytes, expected[i].Bytes) {
+			t.Fatalf("Token %d: expecting %v, got %v.", i, expected[i], tok)
+		}
+	}
+
+	if _, err = d.Token(); err != io.EOF {
+		t.Fatal(errErrorExpected)
+	}
+
+	if _, err = d.BulkBytes(nil); err != ErrNoBulkToken {
+		t.Fatal(errErrorExpected)
+	}
+}
+
+func TestDecoderSkip(t *testing.T) {
+	var err error
+	var tok Token
+
+	stream := "*3\r\n$3\r\nfoo\r\n*2\r\n:1\r\n$3\r\nbar\r\n:2\r\n" +
+		"$6\r\nfoobar\r\n" +
+		"*2\r\n$3\r\nbaz\r\n:3\r\n"
+
+	d := NewDecoder(bytes.NewBufferString(stream))
+
+	// Skipping a whole reply after reading its length.
+	if tok, err = d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if tok.Type != ArrayStartToken || tok.Len != 3 {
+		t.Fatal(errTestFailed)
+	}
+	if err = d.Skip(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Skipping the contents of a bulk message.
+	if tok, err = d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if tok.Type != BulkToken || tok.Len != 6 {
+		t.Fatal(errTestFailed)
+	}
+	if err = d.Skip(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mixing tokens and decoding.
+	if tok, err = d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if tok.Type != ArrayStartToken || tok.Len != 2 {
+		t.Fatal(errTestFailed)
+	}
+
+	if tok, err = d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if tok.Type != BulkToken {
+		t.Fatal(errTestFailed)
+	}
+
+	var i int
+	if err = d.Decode(&i); err != nil {
+		t.Fatal(err)
+	}
+	if i != 3 {
+		t.Fatal(errTestFailed)
+	}
+
+	if tok, err = d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if tok.Type != ArrayEndToken {
+		t.Fatal(errTestFailed)
+	}
+
+	if _, err = d.Token(); err != io.EOF {
+		t.Fatal(errErrorExpected)
+	}
+}
+
+func TestDecoderPeek(t *testing.T) {
+	var err error
+	var lineType byte
+	var n int
+
+	d := NewDecoder(iotest.OneByteReader(bytes.NewBufferString("*2\r\n$3\r\nfoo\r\n$-1\r\n-ERR failed\r\n")))
+
+	if d.Buffered() != 0 {
+		t.Fatal(errTestFailed)
+	}
+
+	for i := 0; i < 2; i++ {
+		if lineType, n, err = d.Peek(); err != nil {
+			t.Fatal(err)
+		}
+		if lineType != ArrayHeader || n != 2 {
+			t.Fatal(errTestFailed)
+		}
+	}
+
+	if d.Buffered() != 4 {
+		t.Fatal(errTestFailed)
+	}
+
+	var tok Token
+	if tok, err = d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if tok.Type != ArrayStartToken {
+		t.Fatal(errTestFailed)
+	}
+
+	if lineType, n, err = d.Peek(); err != nil {
+		t.Fatal(err)
+	}
+	if lineType != BulkHeader || n != 3 {
+		t.Fatal(errTestFailed)
+	}
+
+	if tok, err = d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if tok.Type != BulkToken {
+		t.Fatal(errTestFailed)
+	}
+
+	if err = d.Skip(); err != nil {
+		t.Fatal(err)
+	}
+
+	if lineType, n, err = d.Peek(); err != nil {
+		t.Fatal(err)
+	}
+	if lineType != BulkHeader || n != -1 {
+		t.Fatal(errTestFailed)
+	}
+
+	var s string
+	if err = d.Decode(&s); err != ErrMessageIsNil {
+		t.Fatal(errErrorExpected)
+	}
+
+	if lineType, n, err = d.Peek(); err != nil {
+		t.Fatal(err)
+	}
+	if lineType != ErrorHeader || n != 0 {
+		t.Fatal(errTestFailed)
+	}
+
+	if err = d.Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s != "ERR failed" {
+		t.Fatal(errTestFailed)
+	}
+
+	if _, _, err = d.Peek(); err != io.EOF {
+		t.Fatal(errErrorExpected)
+	}
+
+	if d.Poisoned() {
+		t.Fatal(errTestFailed)
+	}
+
+	// The input ends in the middle of an array.
+	d = NewDecoder(bytes.NewBufferString("*2\r\n:1\r\n"))
+
+	for i := 0; i < 2; i++ {
+		if _, err = d.Token(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, _, err = d.Peek(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Expecting io.ErrUnexpectedEOF, got %v.", err)
+	}
+
+	if !d.Poisoned() {
+		t.Fatal(errTestFailed)
+	}
+}
+
+func TestDecoderPoisoned(t *testing.T) {
+	var err error
+	var s string
+
+	// Missing EOL marker after a bulk message.
+	d := NewDecoder(bytes.NewBufferString("+OK\r\n$3\r\nfoobar\r\n+PONG\r\n"))
+
+	if err = d.Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Decode(&s); err != ErrInvalidInput {
+		t.Fatal(errErrorExpected)
+	}
+
+	if d.Poisoned() != true {
+		t.Fatal(errTestFailed)
+	}
+
+	if err = d.Decode(&s); err != ErrDecoderPoisoned {
+		t.Fatal(errErrorExpected)
+	}
+
+	var n int
+	if n, err = d.Resync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// "ar\r\n" was discarded.
+	if n != 4 {
+		t.Fatalf("Expecting 4 discarded bytes, got %d.", n)
+	}
+
+	if d.Poisoned() != false {
+		t.Fatal(errTestFailed)
+	}
+
+	if err = d.Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	if s != "PONG" {
+		t.Fatal(errTestFailed)
+	}
+
+	if err = d.Decode(&s); err != io.EOF {
+		t.Fatal(errErrorExpected)
+	}
+
+	if d.Poisoned() != false {
+		t.Fatal(errTestFailed)
+	}
+
+	// Truncated array.
+	d = NewDecoder(bytes.NewBufferString("*2\r\n:1\r\n"))
+
+	if err = d.Decode(&s); err != io.ErrUnexpectedEOF {
+		t.Fatal(errErrorExpected)
+	}
+
+	if d.Poisoned() != true {
+		t.Fatal(errTestFailed)
+	}
+}
+
+func TestDecoderResync(t *testing.T) {
+	var err error
+	var n int
+	var m Message
+
+	d := NewDecoder(bytes.NewBufferString("garbage\r\n$x\r\n:1\r\n"))
+
+	if err = d.Decode(&m); err != ErrInvalidInput {
+		t.Fatal(errErrorExpected)
+	}
+
+	// The invalid line was already consumed, "$x\r\n" is not a valid header.
+	if n, err = d.Resync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 4 {
+		t.Fatalf("Expecting 4 discarded bytes, got %d.", n)
+	}
+
+	if err = d.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Integer != 1 {
+		t.Fatal(errTestFailed)
+	}
+
+	// Resync at the end of the input.
+	d = NewDecoder(bytes.NewBufferString("*2\r\n:1\r\n:x"))
+
+	if err = d.Decode(&m); err != io.ErrUnexpectedEOF {
+		t.Fatal(errErrorExpected)
+	}
+
+	if n, err = d.Resync(); err != io.EOF {
+		t.Fatal(errErrorExpected)
+	}
+
+	if n != 0 {
+		t.Fatalf("Expecting no discarded bytes, got %d.", n)
+	}
+
+	// Trailing garbage.
+	d = NewDecoder(bytes.NewBufferString("$9\r\nfoo\r\nxyz"))
+
+	if err = d.Decode(&m); err != io.ErrUnexpectedEOF {
+		t.Fatal(errErrorExpected)
+	}
+
+	d = NewDecoder(bytes.NewBufferString("$3\r\nfoo\r\r\nxyz"))
+
+	if err = d.Decode(&m); err != ErrInvalidInput {
+		t.Fatal(errErrorExpected)
+	}
+
+	if n, err = d.Resync(); err != io.EOF {
+		t.Fatal(errErrorExpected)
+	}
+
+	if n != 4 {
+		t.Fatalf("Expecting 4 discarded bytes, got %d.", n)
+	}
+}
+
+func TestDecodeContext(t *testing.T) {
+	var err error
+	var s string
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	d := NewDecoder(client)
+
+	// Cancelled while waiting for a message.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	if err = d.DecodeContext(ctx, &s); err != context.Canceled {
+		t.Fatalf("Expecting context.Canceled, got %v.", err)
+	}
+
+	if d.Poisoned() != false {
+		t.Fatal(errTestFailed)
+	}
+
+	// Deadline exceeded in the middle of a message.
+	go server.Write([]byte("*2\r\n+OK\r\n"))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var m Message
+	if err = d.DecodeContext(ctx, &m); err != context.DeadlineExceeded {
+		t.Fatalf("Expecting context.DeadlineExceeded, got %v.", err)
+	}
+
+	if d.Poisoned() != true {
+		t.Fatal(errTestFailed)
+	}
+
+	// A fresh decoder on the same connection.
+	d = NewDecoder(client)
+
+	go server.Write([]byte("+PONG\r\n"))
+
+	if err = d.DecodeContext(context.Background(), &s); err != nil {
+		t.Fatal(err)
+	}
+
+	if s != "PONG" {
+		t.Fatal(errTestFailed)
+	}
+
+	// Context that is already done.
+	if err = d.DecodeContext(ctx, &s); err != context.DeadlineExceeded {
+		t.Fatal(errErrorExpected)
+	}
+}
+
+func TestEncodeContext(t *testing.T) {
+	var err error
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	e := NewEncoder(client)
+
+	// Nobody is reading from the other end.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err = e.EncodeContext(ctx, []byte("PING")); err != context.DeadlineExceeded {
+		t.Fatalf("Expecting context.DeadlineExceeded, got %v.", err)
+	}
+
+	go func() {
+		var raw RawMessage
+		NewDecoder(server).Decode(&raw)
+	}()
+
+	if err = e.EncodeContext(context.Background(), []byte("PING")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without deadlines.
+	if err = NewEncoder(bytes.NewBuffer(nil)).EncodeContext(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type testStringer struct{}
+
+func (testStringer) String() string {
+	return "stringer"
+}
+
+func TestCmd(t *testing.T) {
+	var buf []byte
+	var err error
+
+	cmd := NewCmd("SET", "key", []byte("value")).EX(10 * time.Second).NX()
+
+	if buf, err = Marshal(cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(buf, []byte("*6\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n$2\r\nEX\r\n$2\r\n10\r\n$2\r\nNX\r\n")) == false {
+		t.Fatal(errTestFailed)
+	}
+
+	// Encoder and Marshal produce the same output.
+	b := bytes.NewBuffer(nil)
+	if err = NewEncoder(b).Encode(cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(buf, b.Bytes()) == false {
+		t.Fatal(errTestFailed)
+	}
+
+	// Expiration times are rounded up, so they never become zero.
+	expirations := map[*Cmd]string{
+		NewCmd("SET").EX(500 * time.Millisecond):  "EX 1",
+		NewCmd("SET").EX(1500 * time.Millisecond): "EX 2",
+		NewCmd("SET").EX(2 * time.Second):         "EX 2",
+		NewCmd("SET").PX(time.Microsecond):        "PX 1",
+		NewCmd("SET").PX(1500 * time.Microsecond): "PX 2",
+		NewCmd("SET").PX(3 * time.Millisecond):    "PX 3",
+	}
+
+	for c, expected := range expirations {
+		args := c.Args()
+		if s := string(args[1]) + " " + string(args[2]); s != expected {
+			t.Fatalf("Expecting %q, got %q.", expected, s)
+		}
+	}
+
+	cmd = NewCmd("X", int8(-1), uint64(18446744073709551615), 1.5, float32(0.25), math.Inf(-1), true, false, 1500*time.Millisecond, testStringer{}).
+		PX(time.Second).
+		Flag("WITHSCORES", true).
+		Flag("LIMIT", false).
+		Opt("COUNT", 10).
+		Pairs("a", 1, "b", 2)
+
+	expected := []string{"X", "-1", "18446744073709551615", "1.5", "0.25", "-inf", "1", "0", "1.5", "stringer", "PX", "1000", "WITHSCORES", "COUNT", "10", "a", "1", "b", "2"}
+
+	args := cmd.Args()
+	if len(args) != len(expected) {
+		t.Fatalf("Expecting %d arguments, got %d.", len(expected), len(args))
+	}
+
+	for i := range expected {
+		if string(args[i]) != expected[i] {
+			t.Fatalf("Expecting %q, got %q.", expected[i], args[i])
+		}
+	}
+
+	if cmd.Name() != "X" || cmd.Err() != nil {
+		t.Fatal(errTestFailed)
+	}
+
+	// Unsupported arguments.
+	cmd = NewCmd("X", struct{}{})
+
+	if cmd.Err() == nil {
+		t.Fatal(errErrorExpected)
+	}
+
+	if _, err = Marshal(cmd); err == nil {
+		t.Fatal(errErrorExpected)
+	}
+
+	if NewCmd("X").Pairs("a").Err() != ErrOddNumberOfPairs {
+		t.Fatal(errErrorExpected)
+	}
+}
+
+func TestStreamsUnmarshal(t *testing.T) {
+	var err error
+
+	// XREAD reply, the second entry was deleted.
+	var streams []XStream
+	if err = Unmarshal([]byte("*1\r\n*2\r\n$6\r\nevents\r\n*2\r\n*2\r\n$3\r\n1-0\r\n*4\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$1\r\n2\r\n*2\r\n$3\r\n2-0\r\n*-1\r\n"), &streams); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(streams) != 1 || streams[0].Stream != "events" || len(streams[0].Messages) != 2 {
+		t.Fatal(errTestFailed)
+	}
+
+	msg := streams[0].Messages[0]
+	if msg.ID != "1-0" || msg.Values["a"] != "1" || msg.Values["b"] != "2" {
+		t.Fatal(errTestFailed)
+	}
+
+	if streams[0].Messages[1].ID != "2-0" || streams[0].Messages[1].Values != nil {
+		t.Fatal(errTestFailed)
+	}
+
+	// XREAD timed out.
+	if err = Unmarshal([]byte("*-1\r\n"), &streams); err != ErrMessageIsNil {
+		t.Fatal(errErrorExpected)
+	}
+
+	var pending XPending
+	if err = Unmarshal([]byte("*4\r\n:3\r\n$3\r\n1-0\r\n$3\r\n3-0\r\n*2\r\n*2\r\n$5\r\nalice\r\n$1\r\n2\r\n*2\r\n$3\r\nbob\r\n$1\r\n1\r\n"), &pending); err != nil {
+		t.Fatal(err)
+	}
+
+	if pending.Count != 3 || pending.Lower != "1-0" || pending.Higher != "3-0" || pending.Consumers["alice"] != 2 || pending.Consumers["bob"] != 1 {
+		t.Fatal(errTestFailed)
+	}
+
+	if err = Unmarshal([]byte("*4\r\n:0\r\n$-1\r\n$-1\r\n*-1\r\n"), &pending); err != nil {
+		t.Fatal(err)
+	}
+
+	if pending.Count != 0 || pending.Consumers != nil {
+		t.Fatal(errTestFailed)
+	}
+
+	var entries []XPendingEntry
+	if err = Unmarshal([]byte("*1\r\n*4\r\n$3\r\n1-0\r\n$5\r\nalice\r\n:1500\r\n:2\r\n"), &entries); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].ID != "1-0" || entries[0].Consumer != "alice" || entries[0].Idle != 1500*time.Millisecond || entries[0].RetryCount != 2 {
+		t.Fatal(errTestFailed)
+	}
+
+	var claimed XAutoClaimResult
+	if err = Unmarshal([]byte("*3\r\n$3\r\n0-0\r\n*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n*1\r\n$3\r\n2-0\r\n"), &claimed); err != nil {
+		t.Fatal(err)
+	}
+
+	if claimed.Next != "0-0" || len(claimed.Messages) != 1 || claimed.Messages[0].Values["a"] != "1" || len(claimed.Deleted) != 1 || claimed.Deleted[0] != "2-0" {
+		t.Fatal(errTestFailed)
+	}
+
+	// Error replies.
+	if err = Unmarshal([]byte("-NOGROUP No such key\r\n"), &pending); err == nil || err.Error() != "NOGROUP No such key" {
+		t.Fatal(errErrorExpected)
+	}
+}
+
+func TestStreamsCmd(t *testing.T) {
+	commands := map[string]*Cmd{
+		"XADD events * a 1":                              XAdd("events", "*", "a", 1),
+		"XREAD COUNT 10 BLOCK 1500 STREAMS a b $ $":      XRead(XReadArgs{Streams: []string{"a", "b"}, Count: 10, Block: 1500 * time.Millisecond}),
+		"XREADGROUP GROUP g c BLOCK 0 NOACK STREAMS a >": XReadGroup(XReadArgs{Group: "g", Consumer: "c", Streams: []string{"a"}, Block: -1, NoAck: true}),
+		"XREADGROUP GROUP g c STREAMS a 0":               XReadGroup(XReadArgs{Group: "g", Consumer: "c", Streams: []string{"a"}, IDs: []string{"0"}}),
+		"XACK a g 1-0 2-0":                               XAck("a", "g", "1-0", "2-0"),
+		"XPENDING a g - + 10":                            XPendingRange("a", "g", "-", "+", 10),
+		"XAUTOCLAIM a g c 60000 0-0 COUNT 25":            XAutoClaim("a", "g", "c", time.Minute, "0-0", 25),
+		"XRANGE a - + COUNT 5":                           XRange("a", "-", "+").Opt("COUNT", 5),
+	}
+
+	for expected, cmd := range commands {
+		args := make([]string, len(cmd.Args()))
+		for i, arg := range cmd.Args() {
+			args[i] = string(arg)
+		}
+		if s := strings.Join(args, " "); s != expected {
+			t.Fatalf("Expecting %q, got %q.", expected, s)
+		}
+	}
+}
+
+func TestXConsume(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	replies := []string{
+		"*1\r\n*2\r\n$6\r\nevents\r\n*2\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n*2\r\n$3\r\n2-0\r\n*2\r\n$1\r\na\r\n$1\r\n2\r\n",
+		":1\r\n",
+		":1\r\n",
+		"*-1\r\n",
+	}
+
+	received := make(chan []string, 10)
+
+	go func() {
+		d := NewDecoder(server)
+		for i := 0; ; i++ {
+			var args []string
+			if err := d.Decode(&args); err != nil {
+				return
+			}
+			received <- args
+			if i < len(replies) {
+				server.Write([]byte(replies[i]))
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var values []string
+	handle := func(stream string, msg XMessage) error {
+		values = append(values, stream+":"+msg.ID+":"+msg.Values["a"])
+		return nil
+	}
+
+	var commands []string
+	go func() {
+		// Waits for the third XREADGROUP, which is never answered.
+		for i := 0; i < 5; i++ {
+			commands = append(commands, strings.Join(<-received, " "))
+		}
+		cancel()
+	}()
+
+	err := XConsume(ctx, NewEncoder(client), NewDecoder(client), XReadArgs{Group: "g", Consumer: "c", Streams: []string{"events"}}, handle)
+	if err != context.Canceled {
+		t.Fatalf("Expecting context.Canceled, got %v.", err)
+	}
+
+	// Without a timeout XREADGROUP blocks until there are entries.
+	if commands[0] != "XREADGROUP GROUP g c BLOCK 0 STREAMS events >" {
+		t.Fatalf("Unexpected command %q.", commands[0])
+	}
+
+	if strings.Join(values, ",") != "events:1-0:1,events:2-0:2" {
+		t.Fatalf("Unexpected values %v.", values)
+	}
+}
+
+func TestScanIterator(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	replies := map[string]string{
+		"HSCAN h 0 MATCH f* COUNT 2":  "*2\r\n$2\r\n17\r\n*4\r\n$2\r\nf1\r\n$1\r\n1\r\n$2\r\nf2\r\n$1\r\n2\r\n",
+		"HSCAN h 17 MATCH f* COUNT 2": "*2\r\n$2\r\n34\r\n*0\r\n",
+		"HSCAN h 34 MATCH f* COUNT 2": "*2\r\n$1\r\n0\r\n*2\r\n$2\r\nf3\r\n$1\r\n3\r\n",
+		"SCAN 0 TYPE string":          "-ERR syntax error\r\n",
+	}
+
+	go func() {
+		d := NewDecoder(server)
+		for {
+			var args []string
+			if err := d.Decode(&args); err != nil {
+				return
+			}
+			reply, ok := replies[strings.Join(args, " ")]
+			if !ok {
+				reply = "-ERR unexpected command\r\n"
+			}
+			server.Write([]byte(reply))
+		}
+	}()
+
+	e, d := NewEncoder(client), NewDecoder(client)
+
+	it := HScan(e, d, "h", ScanArgs{Match: "f*", Count: 2})
+
+	values := map[string]int{}
+	for it.Next() {
+		var field string
+		if err := it.Decode(&field); err != nil {
+			t.Fatal(err)
+		}
+		if it.Next() == false {
+			t.Fatal(errTestFailed)
+		}
+		var value int
+		if err := it.Decode(&value); err != nil {
+			t.Fatal(err)
+		}
+		values[field] = value
+	}
+
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(values) != 3 || values["f1"] != 1 || values["f2"] != 2 || values["f3"] != 3 {
+		t.Fatalf("Unexpected values %v.", values)
+	}
+
+	// Error replies stop the iterator.
+	it = Scan(e, d, ScanArgs{Type: "string"})
+
+	if it.Next() != false {
+		t.Fatal(errTestFailed)
+	}
+
+	if err := it.Err(); err == nil || err.Error() != "ERR syntax error" {
+		t.Fatal(errErrorExpected)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	codes := map[string]string{
+		"NOSCRIPT No matching script. Please use EVAL.": "NOSCRIPT",
+		"ERR unknown command":                           "ERR",
+		"WRONGPASS":                                     "WRONGPASS",
+		"resp: Invalid input":                           "",
+		"Error message":                                 "",
+		"":                                              "",
+	}
+
+	for s, code := range codes {
+		if c := ErrorCode(errors.New(s)); c != code {
+			t.Fatalf("Expecting %q, got %q.", code, c)
+		}
+	}
+
+	if ErrorCode(nil) != "" {
+		t.Fatal(errTestFailed)
+	}
+}
+
+func TestScript(t *testing.T) {
+	script := NewScript("return {KEYS[1], ARGV[1], {1, 2}}")
+
+	if script.Hash() != "fbfa63bb61c5cd5f212b5c256d83da4c7a281a07" {
+		t.Fatalf("Unexpected hash %s.", script.Hash())
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	loaded := false
+	commands := make(chan string, 10)
+
+	go func() {
+		d := NewDecoder(server)
+		for {
+			var args []string
+			if err := d.Decode(&args); err != nil {
+				return
+			}
+			commands <- args[0]
+			switch {
+			case args[0] == "EVALSHA" && !loaded:
+				server.Write([]byte("-NOSCRIPT No matching script. Please use EVAL.\r\n"))
+			case args[0] == "EVALSHA" || args[0] == "EVAL":
+				loaded = true
+				server.Write([]byte("*3\r\n$1\r\nk\r\n$1\r\nv\r\n*2\r\n:1\r\n:2\r\n"))
+			case args[0] == "FCALL":
+				server.Write([]byte("-ERR Function not found\r\n"))
+			}
+		}
+	}()
+
+	e, d := NewEncoder(client), NewDecoder(client)
+
+	var reply Message
+	if err := script.Run(e, d, &reply, []string{"k"}, "v"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := reply.Get(2, 1); err != nil || n.Integer != 2 {
+		t.Fatal(errTestFailed)
+	}
+
+	var values []interface{}
+	if err := script.Run(e, d, &values, []string{"k"}, "v"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(values) != 3 {
+		t.Fatal(errTestFailed)
+	}
+
+	var sequence []string
+	for i := 0; i < 3; i++ {
+		sequence = append(sequence, <-commands)
+	}
+
+	if strings.Join(sequence, " ") != "EVALSHA EVAL EVALSHA" {
+		t.Fatalf("Unexpected commands %v.", sequence)
+	}
+
+	if err := e.Encode(FCall("missing", []string{"k"}, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Decode(&reply); err != nil {
+		t.Fatal(err)
+	}
+
+	if reply.Type != ErrorHeader || ErrorCode(reply.Error) != "ERR" {
+		t.Fatal(errTestFailed)
+	}
+
+	args := FCallRO("f", []string{"a", "b"}, "c").Args()
+	if len(args) != 6 || string(args[0]) != "FCALL_RO" || string(args[2]) != "2" || string(args[5]) != "c" {
+		t.Fatal(errTestFailed)
+	}
+}
+
+// Starts a fake server that answers each command with the reply given by
+// handle, or doesn't answer if the reply is empty, and returns the client side
+// of the connection.
+func fakeServer(handle func(args []string) string) net.Conn {
+	client, server := net.Pipe()
+
+	go func() {
+		defer server.Close()
+		d := NewDecoder(server)
+		for {
+			var args []string
+			if err := d.Decode(&args); err != nil {
+				return
+			}
+			reply := handle(args)
+			if reply == "" {
+				continue
+			}
+			if _, err := server.Write([]byte(reply)); err != nil {
+				return
+			}
+		}
+	}()
+
+	return client
+}
+
+func TestScriptLoad(t *testing.T) {
+	good := NewScript("return 1")
+	bad := NewScript("return +")
+
+	conn := fakeServer(func(args []string) string {
+		if args[0] != "SCRIPT" || args[1] != "LOAD" {
+			return "-ERR unknown command\r\n"
+		}
+		if args[2] == bad.src {
+			return "-ERR Error compiling script (new function): user_script:1: unexpected symbol near '+'\r\n"
+		}
+		return "$40\r\n" + good.Hash() + "\r\n"
+	})
+	defer conn.Close()
+
+	e, d := NewEncoder(conn), NewDecoder(conn)
+
+	if err := good.Load(e, d); err != nil {
+		t.Fatal(err)
+	}
+
+	err := bad.Load(e, d)
+	if err == nil || !strings.HasPrefix(err.Error(), "ERR Error compiling script") {
+		t.Fatalf("Expecting a compile error, got %v.", err)
+	}
+
+	// The server returned the hash of another script.
+	if err = NewScript("return 2").Load(e, d); err != ErrInvalidInput {
+		t.Fatal(errErrorExpected)
+	}
+}
+
+func TestHello(t *testing.T) {
+	var commands []string
+
+	conn := fakeServer(func(args []string) string {
+		commands = append(commands, strings.Join(args, " "))
+		switch args[0] {
+		case "HELLO":
+			return "*14\r\n$6\r\nserver\r\n$5\r\nredis\r\n$7\r\nversion\r\n$5\r\n7.2.0\r\n$5\r\nproto\r\n:2\r\n$2\r\nid\r\n:7\r\n$4\r\nmode\r\n$10\r\nstandalone\r\n$4\r\nrole\r\n$6\r\nmaster\r\n$7\r\nmodules\r\n*1\r\n*4\r\n$4\r\nname\r\n$4\r\njson\r\n$3\r\nver\r\n:1\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer conn.Close()
+
+	info, err := Hello(NewEncoder(conn), NewDecoder(conn), &HelloArgs{Password: "secret", ClientName: "app", DB: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ServerInfo{Server: "redis", Version: "7.2.0", Proto: 2, ID: 7, Mode: "standalone", Role: "master", Modules: []string{"json"}}
+	if !reflect.DeepEqual(*info, expected) {
+		t.Fatalf("Unexpected %#v.", info)
+	}
+
+	if strings.Join(commands, "|") != "HELLO 2 AUTH default secret SETNAME app|SELECT 1" {
+		t.Fatalf("Unexpected commands %v.", commands)
+	}
+
+	if _, err = Hello(NewEncoder(conn), NewDecoder(conn), &HelloArgs{Proto: 3}); err == nil {
+		t.Fatal(errErrorExpected)
+	}
+}
+
+func TestHelloFallback(t *testing.T) {
+	var commands []string
+
+	conn := fakeServer(func(args []string) string {
+		commands = append(commands, strings.Join(args, " "))
+		switch args[0] {
+		case "HELLO":
+			return "-ERR unknown command 'HELLO'\r\n"
+		case "AUTH":
+			if args[1] != "secret" {
+				return "-ERR invalid password\r\n"
+			}
+		}
+		return "+OK\r\n"
+	})
+	defer conn.Close()
+
+	e, d := NewEncoder(conn), NewDecoder(conn)
+
+	info, err := Hello(e, d, &HelloArgs{Password: "secret", ClientName: "app", DB: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Proto != 2 || info.Server != "" {
+		t.Fatal(errTestFailed)
+	}
+
+	if strings.Join(commands, "|") != "HELLO 2 AUTH default secret SETNAME app|AUTH secret|CLIENT SETNAME app|SELECT 2" {
+		t.Fatalf("Unexpected commands %v.", commands)
+	}
+
+	_, err = Hello(e, d, &HelloArgs{Password: "wrong"})
+	if authErr, ok := err.(*AuthError); !ok || authErr.Code != "ERR" || authErr.Message != "ERR invalid password" {
+		t.Fatalf("Expecting an *AuthError, got %v.", err)
+	}
+}
+
+func TestHelloAuthError(t *testing.T) {
+	conn := fakeServer(func(args []string) string {
+		switch args[0] {
+		case "HELLO":
+			if len(args) > 2 && args[4] != "secret" {
+				return "-WRONGPASS invalid username-password pair or user is disabled.\r\n"
+			}
+			return "*2\r\n$5\r\nproto\r\n:2\r\n"
+		case "SELECT":
+			return "-NOAUTH Authentication required.\r\n"
+		case "CLIENT":
+			return "+OK\r\n"
+		}
+		return "-ERR unknown command\r\n"
+	})
+	defer conn.Close()
+
+	e, d := NewEncoder(conn), NewDecoder(conn)
+
+	_, err := Hello(e, d, &HelloArgs{User: "app", Password: "wrong"})
+	if authErr, ok := err.(*AuthError); !ok || authErr.Code != "WRONGPASS" {
+		t.Fatalf("Expecting WRONGPASS, got %v.", err)
+	}
+
+	_, err = Hello(e, d, &HelloArgs{DB: 3})
+	if authErr, ok := err.(*AuthError); !ok || authErr.Code != "NOAUTH" || ErrorCode(err) != "NOAUTH" {
+		t.Fatalf("Expecting NOAUTH, got %v.", err)
+	}
+
+	called := false
+	onConnect := func(e *Encoder, d *Decoder) error {
+		called = true
+		var reply Message
+		return roundTrip(e, d, NewCmd("CLIENT", "NO-EVICT", "on"), &reply)
+	}
+
+	if _, err = Hello(e, d, &HelloArgs{Password: "secret", OnConnect: onConnect}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !called {
+		t.Fatal(errTestFailed)
+	}
+
+	failing := func(e *Encoder, d *Decoder) error {
+		return errTestFailed
+	}
+
+	if _, err = Hello(e, d, &HelloArgs{Password: "secret", OnConnect: failing}); err != errTestFailed {
+		t.Fatal(errErrorExpected)
+	}
+}
+
+func TestHashSlot(t *testing.T) {
+	if crc16("123456789") != 0x31c3 {
+		t.Fatal(errTestFailed)
+	}
+
+	slots := map[string]int{
+		"":                     0,
+		"foo":                  12182,
+		"somekey":              11058,
+		"{user1000}.following": HashSlot("user1000"),
+		"{user1000}.followers": HashSlot("user1000"),
+		"foo{{bar}}zap":        HashSlot("{bar"),
+		"foo{bar}{zap}":        HashSlot("bar"),
+	}
+
+	for key, slot := range slots {
+		if s := HashSlot(key); s != slot {
+			t.Fatalf("%q: expecting slot %d, got %d.", key, slot, s)
+		}
+	}
+
+	if HashSlot("foo{}{bar}") == HashSlot("bar") {
+		t.Fatal(errTestFailed)
+	}
+}
+
+func TestParseRedirect(t *testing.T) {
+	r := ParseRedirect(errors.New("MOVED 3999 127.0.0.1:6381"))
+	if r == nil || r.Ask || r.Slot != 3999 || r.Addr != "127.0.0.1:6381" {
+		t.Fatal(errTestFailed)
+	}
+
+	r = ParseRedirect(errors.New("ASK 12182 10.0.0.2:7000"))
+	if r == nil || !r.Ask || r.Slot != 12182 || r.Addr != "10.0.0.2:7000" {
+		t.Fatal(errTestFailed)
+	}
+
+	invalid := []error{
+		nil,
+		errors.New("ERR unknown command"),
+		errors.New("MOVED 3999"),
+		errors.New("MOVED 16384 127.0.0.1:6381"),
+		errors.New("ASK x 127.0.0.1:6381"),
+	}
+
+	for _, err := range invalid {
+		if ParseRedirect(err) != nil {
+			t.Fatalf("Unexpected redirect for %v.", err)
+		}
+	}
+}
+
+func TestClusterSlots(t *testing.T) {
+	reply := "*2\r\n" +
+		"*4\r\n:0\r\n:5460\r\n*3\r\n$9\r\n127.0.0.1\r\n:30001\r\n$2\r\nn1\r\n*2\r\n$9\r\n127.0.0.1\r\n:30004\r\n" +
+		"*3\r\n:5461\r\n:16383\r\n*3\r\n$3\r\n::1\r\n:30002\r\n$2\r\nn2\r\n"
+
+	var slots []ClusterSlots
+	if err := Unmarshal([]byte(reply), &slots); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []ClusterSlots{
+		{Start: 0, End: 5460, Nodes: []ClusterNode{{"127.0.0.1:30001", "n1"}, {"127.0.0.1:30004", ""}}},
+		{Start: 5461, End: 16383, Nodes: []ClusterNode{{"[::1]:30002", "n2"}}},
+	}
+
+	if !reflect.DeepEqual(slots, expected) {
+		t.Fatalf("Unexpected %#v.", slots)
+	}
+
+	if err := Unmarshal([]byte("*1\r\n*2\r\n:0\r\n:1\r\n"), &slots); err == nil {
+		t.Fatal(errErrorExpected)
+	}
+}
+
+func TestSentinel(t *testing.T) {
+	conn := fakeServer(func(args []string) string {
+		switch strings.Join(args, " ") {
+		case "SENTINEL GET-MASTER-ADDR-BY-NAME mymaster":
+			return "*2\r\n$8\r\n10.0.0.1\r\n$4\r\n6379\r\n"
+		case "SENTINEL GET-MASTER-ADDR-BY-NAME other":
+			return "*-1\r\n"
+		case "ROLE":
+			return "*3\r\n$6\r\nmaster\r\n:3129659\r\n*0\r\n"
+		}
+		return "-ERR unknown command\r\n"
+	})
+	defer conn.Close()
+
+	e, d := NewEncoder(conn), NewDecoder(conn)
+
+	addr, err := SentinelMasterAddr(e, d, "mymaster")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if addr != "10.0.0.1:6379" {
+		t.Fatalf("Unexpected address %q.", addr)
+	}
+
+	if _, err = SentinelMasterAddr(e, d, "other"); err == nil {
+		t.Fatal(errErrorExpected)
+	}
+
+	role, err := Role(e, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if role != "master" {
+		t.Fatal(errTestFailed)
+	}
+
+	sm, err := ParseSwitchMaster("mymaster 10.0.0.1 6379 10.0.0.2 6380")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *sm != (SwitchMaster{Name: "mymaster", OldAddr: "10.0.0.1:6379", NewAddr: "10.0.0.2:6380"}) {
+		t.Fatal(errTestFailed)
+	}
+
+	if _, err = ParseSwitchMaster("mymaster 10.0.0.1 6379"); err == nil {
+		t.Fatal(errErrorExpected)
+	}
+}
+
+func TestCommandTable(t *testing.T) {
+	conn := fakeServer(func(args []string) string {
+		return "*2\r\n" +
+			"*6\r\n$3\r\nget\r\n:2\r\n*2\r\n+readonly\r\n+fast\r\n:1\r\n:1\r\n:1\r\n" +
+			"*10\r\n$3\r\nset\r\n:-3\r\n*2\r\n+write\r\n+denyoom\r\n:1\r\n:1\r\n:1\r\n*0\r\n*0\r\n*0\r\n*0\r\n"
+	})
+	defer conn.Close()
+
+	table, err := LoadCommands(NewEncoder(conn), NewDecoder(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := table["set"]
+	if set.Name != "set" || set.Arity != -3 || set.FirstKey != 1 || set.LastKey != 1 || set.Step != 1 {
+		t.Fatalf("Unexpected %#v.", set)
+	}
+
+	if !set.HasFlag("denyoom") || set.HasFlag("readonly") {
+		t.Fatal(errTestFailed)
+	}
+
+	if !table.ReadOnly(NewCmd("GET", "k")) {
+		t.Fatal(errTestFailed)
+	}
+
+	if table.ReadOnly(NewCmd("SET", "k", "v")) || table.ReadOnly(NewCmd("UNKNOWN")) {
+		t.Fatal(errTestFailed)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+	expected := []time.Duration{
+		time.Millisecond,
+		2 * time.Millisecond,
+		4 * time.Millisecond,
+		8 * time.Millisecond,
+		10 * time.Millisecond,
+		10 * time.Millisecond,
+	}
+
+	for i := range expected {
+		if b := p.Backoff(i + 1); b != expected[i] {
+			t.Fatalf("Attempt %d: expecting %v, got %v.", i+1, expected[i], b)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if b := p.Backoff(5); b < 5*time.Millisecond || b > 10*time.Millisecond {
+			t.Fatalf("Unexpected backoff %v.", b)
+		}
+	}
+
+	var zero RetryPolicy
+	if zero.Backoff(1) != DefaultMinBackoff || zero.Backoff(100) != DefaultMaxBackoff {
+		t.Fatal(errTestFailed)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	_, dialErr := net.Dial("tcp", "127.0.0.1:0")
+	if dialErr == nil {
+		t.Fatal(errErrorExpected)
+	}
+
+	cases := []struct {
+		err        error
+		idempotent bool
+		retryable  bool
+	}{
+		{errors.New("LOADING Redis is loading the dataset in memory"), false, true},
+		{errors.New("TRYAGAIN Multiple keys request during rehashing of slot"), false, true},
+		{errors.New("CLUSTERDOWN The cluster is down"), false, true},
+		{errors.New("MASTERDOWN Link with MASTER is down"), false, true},
+		{errors.New("ERR wrong number of arguments"), true, false},
+		{errors.New("WRONGTYPE Operation against a key holding the wrong kind of value"), true, false},
+		{io.EOF, true, true},
+		{io.EOF, false, false},
+		{io.ErrUnexpectedEOF, true, true},
+		{dialErr, true, true},
+		{dialErr, false, false},
+		{ErrInvalidInput, true, false},
+	}
+
+	for _, c := range cases {
+		if Retryable(c.err, c.idempotent) != c.retryable {
+			t.Fatalf("%v (idempotent: %v): expecting %v.", c.err, c.idempotent, c.retryable)
+		}
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 4, MinBackoff: time.Microsecond}
+	ctx := context.Background()
+
+	calls := 0
+	err := p.Do(ctx, false, func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("LOADING Redis is loading the dataset in memory")
+		}
+		return nil
+	})
+
+	if err != nil || calls != 3 {
+		t.Fatal(errTestFailed)
+	}
+
+	calls = 0
+	err = p.Do(ctx, true, func() error {
+		calls++
+		return io.ErrUnexpectedEOF
+	})
+
+	if err != io.ErrUnexpectedEOF || calls != 4 {
+		t.Fatal(errTestFailed)
+	}
+
+	calls = 0
+	err = p.Do(ctx, false, func() error {
+		calls++
+		return io.ErrUnexpectedEOF
+	})
+
+	if err != io.ErrUnexpectedEOF || calls != 1 {
+		t.Fatal(errTestFailed)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	p.MinBackoff = time.Hour
+	p.MaxBackoff = time.Hour
+
+	err = p.Do(canceled, true, func() error {
+		return io.EOF
+	})
+
+	if err != context.Canceled {
+		t.Fatal(errErrorExpected)
+	}
+}
+
+func TestBlockingTimeout(t *testing.T) {
+	cases := []struct {
+		cmd      *Cmd
+		timeout  time.Duration
+		blocking bool
+	}{
+		{NewCmd("BLPOP", "a", "b", 1.5), 1500 * time.Millisecond, true},
+		{NewCmd("brpop", "a", 0), 0, true},
+		{NewCmd("BLMOVE", "a", "b", "LEFT", "RIGHT", 2), 2 * time.Second, true},
+		{NewCmd("BZMPOP", 3, 1, "z", "MIN"), 3 * time.Second, true},
+		{NewCmd("WAIT", 1, 250), 250 * time.Millisecond, true},
+		{XRead(XReadArgs{Streams: []string{"s"}, IDs: []string{"$"}, Block: 100 * time.Millisecond}), 100 * time.Millisecond, true},
+		{XRead(XReadArgs{Streams: []string{"block"}, IDs: []string{"$"}}), 0, false},
+		{NewCmd("BLPOP", "a", "x"), 0, false},
+		{NewCmd("GET", "a"), 0, false},
+	}
+
+	for _, c := range cases {
+		timeout, blocking := BlockingTimeout(c.cmd)
+		if timeout != c.timeout || blocking != c.blocking {
+			t.Fatalf("%s: expecting %v %v, got %v %v.", c.cmd.Args(), c.timeout, c.blocking, timeout, blocking)
+		}
+	}
+}
+
+func TestDoBlocking(t *testing.T) {
+	conn := fakeServer(func(args []string) string {
+		switch args[0] {
+		case "BLPOP":
+			time.Sleep(50 * time.Millisecond)
+			if args[1] == "empty" {
+				return "*-1\r\n"
+			}
+			return "*2\r\n$4\r\nfull\r\n$1\r\nv\r\n"
+		case "BRPOP":
+			return ""
+		case "BLMOVE":
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		case "WAIT":
+			return ":1\r\n"
+		}
+		return "$-1\r\n"
+	})
+	defer conn.Close()
+
+	e, d := NewEncoder(conn), NewDecoder(conn)
+	ctx := context.Background()
+
+	var values []string
+	if err := DoBlocking(ctx, e, d, NewCmd("BLPOP", "full", 0.1), 10*time.Millisecond, &values); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(values) != 2 || values[1] != "v" {
+		t.Fatal(errTestFailed)
+	}
+
+	if err := DoBlocking(ctx, e, d, NewCmd("BLPOP", "empty", 0.1), 10*time.Millisecond, &values); err != ErrBlockTimeout {
+		t.Fatalf("Expecting ErrBlockTimeout, got %v.", err)
+	}
+
+	var s string
+	err := DoBlocking(ctx, e, d, NewCmd("BLMOVE", "a", "b", "LEFT", "RIGHT", 0.1), 10*time.Millisecond, &s)
+	if ErrorCode(err) != "WRONGTYPE" || s != "" {
+		t.Fatalf("Expecting WRONGTYPE, got %v.", err)
+	}
+
+	if err = DoBlocking(ctx, e, d, NewCmd("WAIT", 1, 100), 10*time.Millisecond, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DoBlocking(ctx, e, d, NewCmd("GET", "k"), 10*time.Millisecond, &values); err != ErrMessageIsNil {
+		t.Fatalf("Expecting ErrMessageIsNil, got %v.", err)
+	}
+
+	canceled, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+
+	if err := DoBlocking(canceled, e, d, NewCmd("BRPOP", "k", 0), 10*time.Millisecond, &values); err != context.DeadlineExceeded {
+		t.Fatalf("Expecting context.DeadlineExceeded, got %v.", err)
+	}
+
+	// The reply to BRPOP is still pending, the next command must not read it.
+	if !d.Poisoned() {
+		t.Fatal(errTestFailed)
+	}
+
+	if err := DoBlocking(ctx, e, d, NewCmd("GET", "k"), 10*time.Millisecond, &values); err != ErrDecoderPoisoned {
+		t.Fatalf("Expecting ErrDecoderPoisoned, got %v.", err)
+	}
+
+	if err := d.Decode(&values); err != ErrDecoderPoisoned {
+		t.Fatalf("Expecting ErrDecoderPoisoned, got %v.", err)
+	}
+}
+
+func TestParseMonitorLine(t *testing.T) {
+	entry, err := ParseMonitorLine(`+1700000000.123456 [0 127.0.0.1:6379] "SET" "k" "v"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := MonitorEntry{
+		Time:    time.Unix(1700000000, 123456000),
+		DB:      0,
+		Addr:    "127.0.0.1:6379",
+		Command: "SET",
+		Args:    []string{"k", "v"},
+	}
+
+	if !reflect.DeepEqual(*entry, expected) {
+		t.Fatalf("Unexpected %#v.", entry)
+	}
+
+	entry, err = ParseMonitorLine(`1700000000.000001 [12 lua] "hset" "a \"b\"" "line\r\n\ttab\\" "\x00\xff" "" "\a\b"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.DB != 12 || entry.Addr != "lua" || entry.Command != "hset" || entry.Time.Nanosecond() != 1000 {
+		t.Fatal(errTestFailed)
+	}
+
+	args := []string{"a \"b\"", "line\r\n\ttab\\", "\x00\xff", "", "\a\b"}
+	if !reflect.DeepEqual(entry.Args, args) {
+		t.Fatalf("Unexpected %q.", entry.Args)
+	}
+
+	entry, err = ParseMonitorLine(`1700000000.123456 [0 unix:/tmp/redis.sock] "PING"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.Addr != "unix:/tmp/redis.sock" || entry.Command != "PING" || len(entry.Args) != 0 {
+		t.Fatal(errTestFailed)
+	}
+
+	invalid := []string{
+		``,
+		`OK`,
+		`1700000000 [0 127.0.0.1:6379] "PING"`,
+		`1700000000.123456 [x 127.0.0.1:6379] "PING"`,
+		`1700000000.123456 [0] "PING"`,
+		`1700000000.123456 [0 127.0.0.1:6379] `,
+		`1700000000.123456 [0 127.0.0.1:6379] PING`,
+		`1700000000.123456 [0 127.0.0.1:6379] "PING`,
+		`1700000000.123456 [0 127.0.0.1:6379] "\x0"`,
+		`1700000000.123456 [0 127.0.0.1:6379] "\`,
+	}
+
+	for _, line := range invalid {
+		if _, err = ParseMonitorLine(line); err == nil {
+			t.Fatalf("Expecting an error for %q.", line)
+		}
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	large := strings.Repeat("v", 100*1024)
+
+	conn := fakeServer(func(args []string) string {
+		if args[0] != "MONITOR" {
+			return "-ERR unknown command\r\n"
+		}
+		return "+OK\r\n" +
+			"+1700000000.123456 [0 127.0.0.1:50000] \"SET\" \"k\" \"v\"\r\n" +
+			"+1700000001.000000 [1 127.0.0.1:50001] \"GET\" \"k\"\r\n" +
+			"+1700000002.000000 [1 127.0.0.1:50001] \"SET\" \"large\" \"" + large + "\"\r\n" +
+			"+invalid\r\n"
+	})
+	defer conn.Close()
+
+	m := NewMonitor(NewEncoder(conn), NewDecoder(conn))
+
+	var commands []string
+	for m.Next() {
+		entry := m.Entry()
+		commands = append(commands, entry.Command+" "+strings.Join(entry.Args, " "))
+	}
+
+	// Lines are longer than DefaultMaxLineLength when arguments are large.
+	if strings.Join(commands, "|") != "SET k v|GET k|SET large "+large {
+		t.Fatalf("Unexpected commands %v.", commands)
+	}
+
+	if m.Err() != ErrInvalidInput || m.Next() {
+		t.Fatal(errErrorExpected)
+	}
+
+	// Error replies and anything that is not a status line stop the monitor.
+	for reply, expected := range map[string]string{
+		"-ERR max number of clients reached\r\n": "ERR max number of clients reached",
+		"$4\r\nPING\r\n":                         ErrInvalidInput.Error(),
+	} {
+		reply := reply
+		conn := fakeServer(func(args []string) string {
+			return "+OK\r\n+1700000000.123456 [0 127.0.0.1:50000] \"PING\"\r\n" + reply
+		})
+
+		m = NewMonitor(NewEncoder(conn), NewDecoder(conn))
+
+		if !m.Next() || m.Entry().Command != "PING" {
+			t.Fatal(errTestFailed)
+		}
+
+		if m.Next() || m.Err() == nil || m.Err().Error() != expected {
+			t.Fatalf("Expecting %q, got %v.", expected, m.Err())
+		}
+
+		conn.Close()
+	}
+}
+
+func TestDataTypes(t *testing.T) {
+	replies := map[string]string{
+		"GET k":                          "$1\r\nv\r\n",
+		"GET missing":                    "$-1\r\n",
+		"SET k v PX 1500 NX":             "+OK\r\n",
+		"SET k v XX":                     "$-1\r\n",
+		"INCRBY n 2":                     ":3\r\n",
+		"DEL a b":                        ":1\r\n",
+		"EXISTS a":                       ":0\r\n",
+		"PEXPIRE k 2000":                 ":1\r\n",
+		"PEXPIRE k 1":                    ":1\r\n",
+		"PTTL k":                         ":1500\r\n",
+		"PTTL persistent":                ":-1\r\n",
+		"PTTL missing":                   ":-2\r\n",
+		"HGET h f":                       "$1\r\n1\r\n",
+		"HSET h f 1 g 2":                 ":2\r\n",
+		"HGETALL h":                      "*4\r\n$1\r\nf\r\n$1\r\n1\r\n$1\r\ng\r\n$1\r\n2\r\n",
+		"RPUSH l a b":                    ":2\r\n",
+		"LPUSH l c":                      ":3\r\n",
+		"LRANGE l 0 -1":                  "*3\r\n$1\r\nc\r\n$1\r\na\r\n$1\r\nb\r\n",
+		"SADD s x y":                     ":2\r\n",
+		"SMEMBERS s":                     "*2\r\n$1\r\nx\r\n$1\r\ny\r\n",
+		"ZADD z 1.5 a 2 b":               ":2\r\n",
+		"ZRANGE z 0 -1 WITHSCORES":       "*4\r\n$1\r\na\r\n$3\r\n1.5\r\n$1\r\nb\r\n$1\r\n2\r\n",
+		"ZRANGE bad 0 -1 WITHSCORES":     "*1\r\n$1\r\na\r\n",
+		"LRANGE wrongtype 0 -1":          "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n",
+		"ZRANGE missing 0 -1 WITHSCORES": "*0\r\n",
+	}
+
+	conn := fakeServer(func(args []string) string {
+		if reply, ok := replies[strings.Join(args, " ")]; ok {
+			return reply
+		}
+		return "-ERR unexpected command\r\n"
+	})
+	defer conn.Close()
+
+	e, d := NewEncoder(conn), NewDecoder(conn)
+
+	if s, err := Get(e, d, "k"); err != nil || s != "v" {
+		t.Fatal(errTestFailed)
+	}
+
+	if _, err := Get(e, d, "missing"); err != ErrMessageIsNil {
+		t.Fatal(errErrorExpected)
+	}
+
+	if ok, err := Set(e, d, "k", "v", SetArgs{TTL: 1500 * time.Millisecond, NX: true}); err != nil || !ok {
+		t.Fatal(errTestFailed)
+	}
+
+	if ok, err := Set(e, d, "k", "v", SetArgs{XX: true}); err != nil || ok {
+		t.Fatal(errTestFailed)
+	}
+
+	if n, err := IncrBy(e, d, "n", 2); err != nil || n != 3 {
+		t.Fatal(errTestFailed)
+	}
+
+	if n, err := Del(e, d, "a", "b"); err != nil || n != 1 {
+		t.Fatal(errTestFailed)
+	}
+
+	if n, err := Exists(e, d, "a"); err != nil || n != 0 {
+		t.Fatal(errTestFailed)
+	}
+
+	if ok, err := Expire(e, d, "k", 2*time.Second); err != nil || !ok {
+		t.Fatal(errTestFailed)
+	}
+
+	if ok, err := Expire(e, d, "k", time.Microsecond); err != nil || !ok {
+		t.Fatal(errTestFailed)
+	}
+
+	ttls := map[string]time.Duration{
+		"k":          1500 * time.Millisecond,
+		"persistent": NoExpiration,
+		"missing":    KeyNotFound,
+	}
+
+	for key, expected := range ttls {
+		if ttl, err := TTL(e, d, key); err != nil || ttl != expected {
+			t.Fatalf("%s: expecting %v, got %v (%v).", key, expected, ttl, err)
+		}
+	}
+
+	if s, err := HGet(e, d, "h", "f"); err != nil || s != "1" {
+		t.Fatal(errTestFailed)
+	}
+
+	if n, err := HSet(e, d, "h", "f", 1, "g", 2); err != nil || n != 2 {
+		t.Fatal(errTestFailed)
+	}
+
+	if _, err := HSet(e, d, "h", "f"); err != ErrOddNumberOfPairs {
+		t.Fatal(errErrorExpected)
+	}
+
+	if h, err := HGetAll(e, d, "h"); err != nil || !reflect.DeepEqual(h, map[string]string{"f": "1", "g": "2"}) {
+		t.Fatal(errTestFailed)
+	}
+
+	if n, err := RPush(e, d, "l", "a", "b"); err != nil || n != 2 {
+		t.Fatal(errTestFailed)
+	}
+
+	if n, err := LPush(e, d, "l", "c"); err != nil || n != 3 {
+		t.Fatal(errTestFailed)
+	}
+
+	if values, err := LRange(e, d, "l", 0, -1); err != nil || strings.Join(values, ",") != "c,a,b" {
+		t.Fatal(errTestFailed)
+	}
+
+	if _, err := LRange(e, d, "wrongtype", 0, -1); ErrorCode(err) != "WRONGTYPE" {
+		t.Fatal(errErrorExpected)
+	}
+
+	if n, err := SAdd(e, d, "s", "x", "y"); err != nil || n != 2 {
+		t.Fatal(errTestFailed)
+	}
+
+	if members, err := SMembers(e, d, "s"); err != nil || len(members) != 2 {
+		t.Fatal(errTestFailed)
+	}
+
+	if n, err := ZAdd(e, d, "z", Z{"a", 1.5}, Z{"b", 2}); err != nil || n != 2 {
+		t.Fatal(errTestFailed)
+	}
+
+	z, err := ZRangeWithScores(e, d, "z", 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(z, []Z{{"a", 1.5}, {"b", 2}}) {
+		t.Fatalf("Unexpected %v.", z)
+	}
+
+	if z, err = ZRangeWithScores(e, d, "missing", 0, -1); err != nil || len(z) != 0 {
+		t.Fatal(errTestFailed)
+	}
+
+	if _, err = ZRangeWithScores(e, d, "bad", 0, -1); err != ErrInvalidInput {
+		t.Fatal(errErrorExpected)
+	}
+
+	var n int64
+	if err = Do(e, d, NewCmd("INCRBY", "n", 2), &n); err != nil || n != 3 {
+		t.Fatal(errTestFailed)
+	}
+
+	if err = Do(e, d, NewCmd("UNKNOWN"), nil); err == nil || err.Error() != "ERR unexpected command" {
+		t.Fatal(errErrorExpected)
+	}
+}
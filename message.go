@@ -21,6 +21,12 @@
 
 package resp
 
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
 const (
 	// StringHeader is the header used to prefix simple strings (or status
 	// messages). String messages are not binary safe.
@@ -44,6 +50,47 @@ type Message struct {
 	Array   []*Message
 	IsNil   bool
 	Type    byte
+
+	// Whether Bytes points into the buffer of a decoder in zero-copy mode, in
+	// which case it must not be reused.
+	shared bool
+}
+
+// RawMessage is a raw RESP encoded message. Decoding into a *RawMessage stores
+// the exact bytes of the next message in the stream without building a
+// Message tree, and encoding a RawMessage writes it verbatim. It can be used
+// to forward messages without decoding and re-encoding them.
+type RawMessage []byte
+
+var messagePool = sync.Pool{
+	New: func() interface{} {
+		return new(Message)
+	},
+}
+
+// AcquireMessage returns an empty Message from a pool of messages that were
+// previously released with ReleaseMessage. Using it together with
+// Decoder.DecodeMessage allows messages to be decoded without allocating new
+// buffers on each call.
+func AcquireMessage() *Message {
+	return messagePool.Get().(*Message)
+}
+
+// ReleaseMessage puts m back into the pool of messages. Neither m nor any
+// value taken from it can be used after calling ReleaseMessage.
+func ReleaseMessage(m *Message) {
+	if m == nil {
+		return
+	}
+	var b []byte
+	if !m.shared {
+		b = m.Bytes[:0]
+	}
+	*m = Message{
+		Bytes: b,
+		Array: m.Array[:0],
+	}
+	messagePool.Put(m)
 }
 
 // SetStatus sets a message of type status.
@@ -68,6 +115,7 @@ func (m *Message) SetInteger(i int64) {
 func (m *Message) SetBytes(b []byte) {
 	m.Type = BulkHeader
 	m.Bytes = b
+	m.shared = false
 }
 
 // SetArray sets a message of type array.
@@ -98,3 +146,167 @@ func (m Message) Interface() interface{} {
 	}
 	return nil
 }
+
+// mismatch returns the error for a message that can't be read as the given
+// type. Error replies are returned as errors themselves.
+func (m *Message) mismatch(dst string) error {
+	if m.Type == ErrorHeader && m.Error != nil {
+		return m.Error
+	}
+	return fmt.Errorf(ErrUnsupportedConversion.Error(), byteToTypeName(m.Type), dst)
+}
+
+// Str returns the message as a string. Status, error, integer and bulk
+// messages can be read as strings.
+func (m *Message) Str() (string, error) {
+	if m.IsNil {
+		return "", ErrMessageIsNil
+	}
+	switch m.Type {
+	case StringHeader:
+		return m.Status, nil
+	case ErrorHeader:
+		if m.Error == nil {
+			return "", nil
+		}
+		return m.Error.Error(), nil
+	case IntegerHeader:
+		return strconv.FormatInt(m.Integer, 10), nil
+	case BulkHeader:
+		return string(m.Bytes), nil
+	}
+	return "", m.mismatch("string")
+}
+
+// Int64 returns the message as an int64. Integer messages and bulk messages
+// that contain a number can be read as integers.
+func (m *Message) Int64() (int64, error) {
+	if m.IsNil {
+		return 0, ErrMessageIsNil
+	}
+	switch m.Type {
+	case IntegerHeader:
+		return m.Integer, nil
+	case BulkHeader:
+		return strconv.ParseInt(string(m.Bytes), 10, 64)
+	}
+	return 0, m.mismatch("int64")
+}
+
+// Float64 returns the message as a float64. Integer messages and bulk
+// messages that contain a number can be read as floats.
+func (m *Message) Float64() (float64, error) {
+	if m.IsNil {
+		return 0, ErrMessageIsNil
+	}
+	switch m.Type {
+	case IntegerHeader:
+		return float64(m.Integer), nil
+	case BulkHeader:
+		return strconv.ParseFloat(string(m.Bytes), 64)
+	}
+	return 0, m.mismatch("float64")
+}
+
+// Bool returns the message as a bool. Only integer messages can be read as
+// booleans, any value other than zero is true.
+func (m *Message) Bool() (bool, error) {
+	if m.IsNil {
+		return false, ErrMessageIsNil
+	}
+	if m.Type == IntegerHeader {
+		return m.Integer != 0, nil
+	}
+	return false, m.mismatch("bool")
+}
+
+// BytesValue returns the contents of a bulk message.
+func (m *Message) BytesValue() ([]byte, error) {
+	if m.IsNil {
+		return nil, ErrMessageIsNil
+	}
+	if m.Type == BulkHeader {
+		return m.Bytes, nil
+	}
+	return nil, m.mismatch("[]byte")
+}
+
+// Slice returns the elements of an array message.
+func (m *Message) Slice() ([]*Message, error) {
+	if m.IsNil {
+		return nil, ErrMessageIsNil
+	}
+	if m.Type == ArrayHeader {
+		return m.Array, nil
+	}
+	return nil, m.mismatch("slice")
+}
+
+// Map returns the elements of an array message that contains a flat list of
+// key/value pairs (like the reply to HGETALL) as a map. Keys are read with
+// Str().
+func (m *Message) Map() (map[string]*Message, error) {
+	a, err := m.Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(a)%2 != 0 {
+		return nil, ErrInvalidInput
+	}
+
+	dst := make(map[string]*Message, len(a)/2)
+
+	for i := 0; i < len(a); i += 2 {
+		var k string
+		if k, err = a[i].Str(); err != nil {
+			return nil, err
+		}
+		dst[k] = a[i+1]
+	}
+
+	return dst, nil
+}
+
+// Get walks the message following the given path and returns the element it
+// points to. An int path element selects an element of an array by index,
+// while a string path element selects the value that follows the matching key
+// in an array of key/value pairs, for instance:
+//
+//	entry, err := m.Get("entries", 0, 1)
+func (m *Message) Get(path ...interface{}) (*Message, error) {
+	cur := m
+
+	for i := range path {
+		a, err := cur.Slice()
+		if err != nil {
+			return nil, fmt.Errorf(ErrPathNotFound.Error(), path[:i+1])
+		}
+
+		var next *Message
+
+		switch p := path[i].(type) {
+		case int:
+			if p >= 0 && p < len(a) {
+				next = a[p]
+			}
+		case string:
+			for j := 0; j+1 < len(a); j += 2 {
+				if k, err := a[j].Str(); err == nil && k == p {
+					next = a[j+1]
+					break
+				}
+			}
+		default:
+			return nil, ErrInvalidPath
+		}
+
+		if next == nil {
+			return nil, fmt.Errorf(ErrPathNotFound.Error(), path[:i+1])
+		}
+
+		cur = next
+	}
+
+	return cur, nil
+}
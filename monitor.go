@@ -0,0 +1,265 @@
+// Copyright (c) 2015 José Carlos Nieto, https://menteslibres.net/xiam
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the
+// "Software"), to deal in the Software without restriction, including
+// without limitation the rights to use, copy, modify, merge, publish,
+// distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to
+// the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+// LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+// WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package resp
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MonitorEntry is a command that the server received, as reported by MONITOR.
+// Addr is the address of the client that sent the command, "lua" for commands
+// sent by scripts, or "unix:" followed by a path for unix socket clients.
+type MonitorEntry struct {
+	Time    time.Time
+	DB      int
+	Addr    string
+	Command string
+	Args    []string
+}
+
+// ParseMonitorLine parses a line sent by MONITOR, like
+//
+//	1700000000.123456 [0 127.0.0.1:6379] "SET" "k" "v"
+//
+// Arguments are unquoted with the escape rules redis uses for them (\", \\,
+// \n, \r, \t, \a, \b and \xHH).
+func ParseMonitorLine(line string) (*MonitorEntry, error) {
+	line = strings.TrimPrefix(line, "+")
+
+	i := strings.Index(line, " [")
+	if i < 0 {
+		return nil, ErrInvalidInput
+	}
+
+	t, err := parseMonitorTime(line[:i])
+	if err != nil {
+		return nil, err
+	}
+
+	line = line[i+2:]
+
+	j := strings.Index(line, "] ")
+	if j < 0 {
+		return nil, ErrInvalidInput
+	}
+
+	client := strings.SplitN(line[:j], " ", 2)
+	if len(client) != 2 {
+		return nil, ErrInvalidInput
+	}
+
+	db, err := strconv.Atoi(client[0])
+	if err != nil {
+		return nil, ErrInvalidInput
+	}
+
+	args, err := unquoteMonitorArgs(line[j+2:])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 0 {
+		return nil, ErrInvalidInput
+	}
+
+	return &MonitorEntry{
+		Time:    t,
+		DB:      db,
+		Addr:    client[1],
+		Command: args[0],
+		Args:    args[1:],
+	}, nil
+}
+
+// Parses a <seconds>.<microseconds> timestamp.
+func parseMonitorTime(s string) (time.Time, error) {
+	dot := strings.IndexByte(s, '.')
+	if dot < 0 || len(s)-dot-1 != 6 {
+		return time.Time{}, ErrInvalidInput
+	}
+
+	sec, err := strconv.ParseInt(s[:dot], 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidInput
+	}
+
+	usec, err := strconv.ParseInt(s[dot+1:], 10, 64)
+	if err != nil || usec < 0 {
+		return time.Time{}, ErrInvalidInput
+	}
+
+	return time.Unix(sec, usec*int64(time.Microsecond)), nil
+}
+
+// Splits a list of space separated, double quoted arguments.
+func unquoteMonitorArgs(s string) ([]string, error) {
+	var args []string
+
+	for i := 0; i < len(s); {
+		if s[i] == ' ' {
+			i++
+			continue
+		}
+
+		if s[i] != '"' {
+			return nil, ErrInvalidInput
+		}
+		i++
+
+		var arg []byte
+		for {
+			if i >= len(s) {
+				return nil, ErrInvalidInput
+			}
+
+			c := s[i]
+			i++
+
+			if c == '"' {
+				break
+			}
+
+			if c != '\\' {
+				arg = append(arg, c)
+				continue
+			}
+
+			if i >= len(s) {
+				return nil, ErrInvalidInput
+			}
+
+			c = s[i]
+			i++
+
+			switch c {
+			case 'n':
+				arg = append(arg, '\n')
+			case 'r':
+				arg = append(arg, '\r')
+			case 't':
+				arg = append(arg, '\t')
+			case 'a':
+				arg = append(arg, '\a')
+			case 'b':
+				arg = append(arg, '\b')
+			case 'x':
+				if i+2 > len(s) {
+					return nil, ErrInvalidInput
+				}
+				n, err := strconv.ParseUint(s[i:i+2], 16, 8)
+				if err != nil {
+					return nil, ErrInvalidInput
+				}
+				arg = append(arg, byte(n))
+				i += 2
+			default:
+				arg = append(arg, c)
+			}
+		}
+
+		args = append(args, string(arg))
+	}
+
+	return args, nil
+}
+
+// Monitor reads the commands that a server receives, after sending MONITOR.
+// Use it like a bufio.Scanner:
+//
+//	m := resp.NewMonitor(e, d)
+//	for m.Next() {
+//		entry := m.Entry()
+//		...
+//	}
+//	if err := m.Err(); err != nil {
+//		...
+//	}
+//
+// A connection in monitor mode can't be used for anything else, so it should
+// be closed once the monitor is no longer needed.
+type Monitor struct {
+	e *Encoder
+	d *Decoder
+
+	started bool
+	entry   *MonitorEntry
+	err     error
+}
+
+// NewMonitor returns a Monitor that writes MONITOR to e and reads the
+// commands from d. Each command is sent as a single line that holds all of
+// its arguments, so the maximum line length of d is disabled (see
+// Decoder.SetMaxLineLength).
+func NewMonitor(e *Encoder, d *Decoder) *Monitor {
+	d.SetMaxLineLength(0)
+	return &Monitor{e: e, d: d}
+}
+
+// Next waits for the next command, which is then available through Entry. It
+// returns false when an error happened.
+func (m *Monitor) Next() bool {
+	m.entry = nil
+
+	if m.err != nil {
+		return false
+	}
+
+	if !m.started {
+		m.started = true
+		if m.err = Do(m.e, m.d, NewCmd("MONITOR"), nil); m.err != nil {
+			return false
+		}
+	}
+
+	var reply Message
+	if m.err = m.d.DecodeMessage(&reply); m.err != nil {
+		return false
+	}
+
+	switch reply.Type {
+	case StringHeader:
+	case ErrorHeader:
+		m.err = replyError(&reply)
+		return false
+	default:
+		m.err = ErrInvalidInput
+		return false
+	}
+
+	if m.entry, m.err = ParseMonitorLine(reply.Status); m.err != nil {
+		return false
+	}
+
+	return true
+}
+
+// Entry returns the current command.
+func (m *Monitor) Entry() *MonitorEntry {
+	return m.entry
+}
+
+// Err returns the first error found by the monitor.
+func (m *Monitor) Err() error {
+	return m.err
+}
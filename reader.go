@@ -27,65 +27,288 @@ import (
 	"io"
 )
 
+// DefaultMaxLineLength is the maximum length of a line, including its header
+// and EOL marker, that a Reader accepts by default.
+const DefaultMaxLineLength = 64 * 1024
+
 // Reader reads Redis tokens from an input stream
 type Reader struct {
 	br *bufio.Reader
+
+	// Holds lines that could not be read in a single slice.
+	line          []byte
+	maxLineLength int
 }
 
 func NewReader(r io.Reader) *Reader {
 	d := &Reader{
-		br: bufio.NewReader(r),
+		br:            bufio.NewReader(r),
+		maxLineLength: DefaultMaxLineLength,
 	}
 	return d
 }
 
-// Read a line of input and its type
+// SetMaxLineLength sets the maximum length of a line, including its header and
+// EOL marker. ReadLine returns ErrLineTooLong when a line exceeds this length.
+// A value of zero or less disables the limit.
+func (r *Reader) SetMaxLineLength(n int) {
+	r.maxLineLength = n
+}
+
+// Read a line of input and its type. The returned line is only valid until the
+// next read.
 func (r *Reader) ReadLine() (lineType byte, line []byte, err error) {
-	buf := bytes.NewBuffer(nil)
 	end := endOfLine[len(endOfLine)-1]
-	for !bytes.HasSuffix(buf.Bytes(), endOfLine) {
-		if tmp, err := r.br.ReadBytes(end); err != nil {
+
+	buf, err := r.br.ReadSlice(end)
+	if err != nil || !bytes.HasSuffix(buf, endOfLine) {
+		// The line was either larger than the reader's buffer or contained an
+		// end byte that was not part of an EOL marker.
+		r.line = append(r.line[:0], buf...)
+		for err == bufio.ErrBufferFull || (err == nil && !bytes.HasSuffix(r.line, endOfLine)) {
+			if r.maxLineLength > 0 && len(r.line) > r.maxLineLength {
+				return 0, nil, ErrLineTooLong
+			}
+			buf, err = r.br.ReadSlice(end)
+			r.line = append(r.line, buf...)
+		}
+		if err != nil {
+			if err == io.EOF && len(r.line) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
 			return 0, nil, err
-		} else {
-			buf.Write(tmp)
 		}
+		buf = r.line
+	}
+
+	if r.maxLineLength > 0 && len(buf) > r.maxLineLength {
+		return 0, nil, ErrLineTooLong
 	}
+
 	// Line must be at least 1 byte + EOL marker
-	if buf.Len() < (1 + len(endOfLine)) {
+	if len(buf) < (1 + len(endOfLine)) {
 		return 0, nil, ErrInvalidInput
 	}
 
-	if lineType, err = buf.ReadByte(); err != nil {
-		return 0, nil, err
+	return buf[0], buf[1 : len(buf)-len(endOfLine)], nil
+}
+
+// Peek returns the header of the next message without consuming it. For bulk
+// messages and arrays Peek also returns their declared length, which is -1 for
+// nil values. Peek only blocks until the header line is available.
+func (r *Reader) Peek() (lineType byte, n int, err error) {
+	var buf []byte
+
+	if buf, err = r.br.Peek(1); err != nil {
+		return 0, 0, err
+	}
+
+	lineType = buf[0]
+	if lineType != BulkHeader && lineType != ArrayHeader {
+		return lineType, 0, nil
+	}
+
+	if buf, err = r.peekLine(); err != nil {
+		return 0, 0, err
+	}
+
+	if n, err = parseLength(buf[1 : len(buf)-len(endOfLine)]); err != nil {
+		return 0, 0, err
 	}
-	buf.Truncate(buf.Len() - len(endOfLine))
-	line = buf.Bytes()
-	return lineType, line, nil
+
+	return lineType, n, nil
 }
 
-// Read a message from Redis of length n bytes (not including EOL marker)
-func (r *Reader) ReadMessageBytes(n int) (buf []byte, err error) {
-	bytesRemaining := n + len(endOfLine)
-	buf = make([]byte, bytesRemaining)
+// Returns the next line, including its EOL marker, without consuming it. Only
+// blocks until the line is available.
+func (r *Reader) peekLine() ([]byte, error) {
+	for size := 1; ; size++ {
+		if size < r.br.Buffered() {
+			size = r.br.Buffered()
+		}
+		buf, err := r.br.Peek(size)
+		if i := bytes.Index(buf, endOfLine); i >= 0 {
+			return buf[:i+len(endOfLine)], nil
+		}
+		if err != nil {
+			if err == bufio.ErrBufferFull {
+				return nil, ErrLineTooLong
+			}
+			if err == io.EOF && len(buf) > 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+}
+
+// Reports whether line, including its EOL marker, looks like the header line of
+// a message.
+func isHeaderLine(line []byte) bool {
+	if len(line) < 1+len(endOfLine) {
+		return false
+	}
+
+	body := line[1 : len(line)-len(endOfLine)]
+
+	switch line[0] {
+	case StringHeader, ErrorHeader:
+		return true
+	case IntegerHeader:
+		_, err := parseInt(body)
+		return err == nil
+	case BulkHeader, ArrayHeader:
+		n, err := parseLength(body)
+		return err == nil && n >= -1
+	}
+
+	return false
+}
+
+// Discards input until the next line that looks like the beginning of a
+// message and returns the number of discarded bytes.
+func (r *Reader) resync() (int, error) {
+	skipped := 0
 
 	for {
-		readStart := len(buf) - bytesRemaining
-		var bytesRead int
-		if bytesRead, err = r.br.Read(buf[readStart:]); err != nil {
-			return nil, err
+		line, err := r.peekLine()
+		if err == ErrLineTooLong {
+			// No EOL marker in a full buffer, keep the last byte in case it is
+			// the beginning of one.
+			n, _ := r.br.Discard(r.br.Buffered() - 1)
+			skipped += n
+			continue
+		}
+		if err != nil {
+			if err == io.ErrUnexpectedEOF {
+				// Trailing garbage.
+				n, _ := r.br.Discard(r.br.Buffered())
+				skipped += n
+				err = io.EOF
+			}
+			return skipped, err
+		}
+
+		if isHeaderLine(line) {
+			return skipped, nil
+		}
+
+		n, err := r.br.Discard(len(line))
+		skipped += n
+		if err != nil {
+			return skipped, err
+		}
+	}
+}
+
+// Buffered returns the number of bytes that can be read from the current
+// buffer without reading from the input stream.
+func (r *Reader) Buffered() int {
+	return r.br.Buffered()
+}
+
+// Parses a decimal integer without converting it into a string first.
+func parseInt(b []byte) (int64, error) {
+	if len(b) == 0 {
+		return 0, ErrInvalidInput
+	}
+
+	neg := false
+	if b[0] == '-' || b[0] == '+' {
+		neg = b[0] == '-'
+		b = b[1:]
+		if len(b) == 0 {
+			return 0, ErrInvalidInput
+		}
+	}
+
+	var n uint64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, ErrInvalidInput
+		}
+		if n > (1<<63)/10 {
+			return 0, ErrInvalidInput
 		}
-		if bytesRead == bytesRemaining {
-			break
-		} else {
-			bytesRemaining -= bytesRead
+		n = n*10 + uint64(c-'0')
+		if n > 1<<63 {
+			return 0, ErrInvalidInput
+		}
+	}
+
+	if neg {
+		return -int64(n), nil
+	}
+	if n > 1<<63-1 {
+		return 0, ErrInvalidInput
+	}
+	return int64(n), nil
+}
+
+// Parses the length of a bulk or array message.
+func parseLength(b []byte) (int, error) {
+	n, err := parseInt(b)
+	if err != nil {
+		return 0, err
+	}
+	if int64(int(n)) != n {
+		return 0, ErrMessageIsTooLarge
+	}
+	return int(n), nil
+}
+
+// Read a message from Redis of length n bytes (not including EOL marker)
+func (r *Reader) ReadMessageBytes(n int) (buf []byte, err error) {
+	return r.readMessageBytes(nil, n)
+}
+
+// Same as ReadMessageBytes but reuses the space of the given buffer, if it is
+// large enough.
+func (r *Reader) readMessageBytes(buf []byte, n int) ([]byte, error) {
+	if buf == nil || cap(buf) < n {
+		buf = make([]byte, n)
+	} else {
+		buf = buf[:n]
+	}
+
+	if _, err := io.ReadFull(r.br, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
 		}
+		return nil, err
 	}
-	// Message must terminate in EOL marker
-	if !bytes.HasSuffix(buf, endOfLine) {
-		return nil, ErrInvalidInput
+
+	if err := r.readEndOfLine(); err != nil {
+		return nil, err
 	}
 
-	// Truncate EOL marker from return buffer
-	buf = buf[:n]
 	return buf, nil
 }
+
+// Discards a message of length n bytes (not including EOL marker)
+func (r *Reader) discardMessageBytes(n int) error {
+	if _, err := r.br.Discard(n); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return r.readEndOfLine()
+}
+
+// Message must terminate in EOL marker
+func (r *Reader) readEndOfLine() error {
+	for i := range endOfLine {
+		c, err := r.br.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if c != endOfLine[i] {
+			return ErrInvalidInput
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2015 José Carlos Nieto, https://menteslibres.net/xiam
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the
+// "Software"), to deal in the Software without restriction, including
+// without limitation the rights to use, copy, modify, merge, publish,
+// distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to
+// the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+// LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+// WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package resp
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// Default values of RetryPolicy.
+const (
+	DefaultMaxAttempts = 3
+	DefaultMinBackoff  = 8 * time.Millisecond
+	DefaultMaxBackoff  = 512 * time.Millisecond
+)
+
+// RetryPolicy describes how many times and how often an operation that failed
+// with a transient error is attempted again. Zero values are replaced by their
+// defaults.
+type RetryPolicy struct {
+	// Maximum number of attempts, including the first one.
+	MaxAttempts int
+	// Wait before the second attempt, doubled after each attempt up to
+	// MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Fraction of each wait, between 0 and 1, that is randomized so clients
+	// don't retry in lockstep.
+	Jitter float64
+}
+
+// Backoff returns the time to wait after the given failed attempt, starting at
+// 1.
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	min, max := p.MinBackoff, p.MaxBackoff
+	if min <= 0 {
+		min = DefaultMinBackoff
+	}
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+
+	b := min
+	for i := 1; i < attempt && b < max; i++ {
+		b *= 2
+	}
+	if b > max {
+		b = max
+	}
+
+	if p.Jitter > 0 {
+		j := p.Jitter
+		if j > 1 {
+			j = 1
+		}
+		b -= time.Duration(rand.Float64() * j * float64(b))
+	}
+
+	return b
+}
+
+// Do calls fn until it succeeds, fails with an error that is not retryable
+// (see Retryable), the attempts run out or ctx is done. Network errors leave
+// the connection in an unknown state, so fn is expected to use a new
+// connection after one.
+func (p *RetryPolicy) Do(ctx context.Context, idempotent bool, fn func() error) error {
+	attempts := p.MaxAttempts
+	if attempts <= 0 {
+		attempts = DefaultMaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= attempts || !Retryable(err, idempotent) {
+			return err
+		}
+
+		t := time.NewTimer(p.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Retryable reports whether an operation that failed with err may succeed if
+// attempted again. Replies like LOADING, TRYAGAIN, CLUSTERDOWN and MASTERDOWN
+// mean that the command was not executed, so they are always retryable.
+// Network errors may happen after the server executed the command, so they are
+// only retryable for idempotent commands.
+func Retryable(err error, idempotent bool) bool {
+	switch ErrorCode(err) {
+	case "LOADING", "TRYAGAIN", "CLUSTERDOWN", "MASTERDOWN":
+		return true
+	}
+
+	if !idempotent {
+		return false
+	}
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF || err == ErrDecoderPoisoned {
+		return true
+	}
+
+	_, ok := err.(net.Error)
+	return ok
+}
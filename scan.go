@@ -0,0 +1,174 @@
+// Copyright (c) 2015 José Carlos Nieto, https://menteslibres.net/xiam
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the
+// "Software"), to deal in the Software without restriction, including
+// without limitation the rights to use, copy, modify, merge, publish,
+// distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to
+// the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+// LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+// WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package resp
+
+// ScanArgs holds the options of SCAN, SSCAN, HSCAN and ZSCAN. Zero values are
+// not sent.
+type ScanArgs struct {
+	Match string
+	Count int64
+	// Type is only used by SCAN.
+	Type string
+}
+
+// ScanIterator iterates over the elements returned by SCAN, SSCAN, HSCAN or
+// ZSCAN, sending the commands needed to follow the cursor until the server
+// returns the zero cursor. Use it like a bufio.Scanner:
+//
+//	it := resp.Scan(e, d, resp.ScanArgs{Match: "user:*"})
+//	for it.Next() {
+//		var key string
+//		if err := it.Decode(&key); err != nil {
+//			...
+//		}
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// HSCAN and ZSCAN return fields and values (or members and scores) as
+// consecutive elements.
+type ScanIterator struct {
+	e    *Encoder
+	d    *Decoder
+	name string
+	key  string
+	args ScanArgs
+
+	cursor  string
+	started bool
+	vals    []*Message
+	cur     *Message
+	err     error
+}
+
+// Scan returns an iterator over the keys of the current database, writing
+// commands to e and reading replies from d.
+func Scan(e *Encoder, d *Decoder, args ScanArgs) *ScanIterator {
+	return newScanIterator(e, d, "SCAN", "", args)
+}
+
+// SScan returns an iterator over the members of a set.
+func SScan(e *Encoder, d *Decoder, key string, args ScanArgs) *ScanIterator {
+	return newScanIterator(e, d, "SSCAN", key, args)
+}
+
+// HScan returns an iterator over the fields and values of a hash.
+func HScan(e *Encoder, d *Decoder, key string, args ScanArgs) *ScanIterator {
+	return newScanIterator(e, d, "HSCAN", key, args)
+}
+
+// ZScan returns an iterator over the members and scores of a sorted set.
+func ZScan(e *Encoder, d *Decoder, key string, args ScanArgs) *ScanIterator {
+	return newScanIterator(e, d, "ZSCAN", key, args)
+}
+
+func newScanIterator(e *Encoder, d *Decoder, name, key string, args ScanArgs) *ScanIterator {
+	return &ScanIterator{
+		e:      e,
+		d:      d,
+		name:   name,
+		key:    key,
+		args:   args,
+		cursor: "0",
+	}
+}
+
+func (it *ScanIterator) cmd() *Cmd {
+	c := NewCmd(it.name)
+	if it.name != "SCAN" {
+		c.Arg(it.key)
+	}
+	c.Arg(it.cursor)
+	if it.args.Match != "" {
+		c.Opt("MATCH", it.args.Match)
+	}
+	if it.args.Count > 0 {
+		c.Opt("COUNT", it.args.Count)
+	}
+	if it.args.Type != "" && it.name == "SCAN" {
+		c.Opt("TYPE", it.args.Type)
+	}
+	return c
+}
+
+// Next advances the iterator to the next element, which is then available
+// through Val and Decode. It returns false when there are no more elements or
+// an error happened.
+func (it *ScanIterator) Next() bool {
+	it.cur = nil
+
+	for len(it.vals) == 0 {
+		if it.err != nil || (it.started && it.cursor == "0") {
+			return false
+		}
+		it.started = true
+
+		if it.err = it.e.Encode(it.cmd()); it.err != nil {
+			return false
+		}
+
+		var reply Message
+		if it.err = it.d.Decode(&reply); it.err != nil {
+			return false
+		}
+
+		// The reply is a [cursor, [element, ...]] array.
+		var a []*Message
+		if a, it.err = reply.Slice(); it.err != nil {
+			return false
+		}
+		if len(a) != 2 {
+			it.err = ErrInvalidInput
+			return false
+		}
+		if it.cursor, it.err = a[0].Str(); it.err != nil {
+			return false
+		}
+		if it.vals, it.err = a[1].Slice(); it.err != nil {
+			return false
+		}
+	}
+
+	it.cur, it.vals = it.vals[0], it.vals[1:]
+
+	return true
+}
+
+// Val returns the current element.
+func (it *ScanIterator) Val() *Message {
+	return it.cur
+}
+
+// Decode stores the current element in the value pointed to by v, applying the
+// same conversions as Unmarshal.
+func (it *ScanIterator) Decode(v interface{}) error {
+	if it.cur == nil {
+		return ErrMessageIsNil
+	}
+	return messageToValue(it.cur, v)
+}
+
+// Err returns the first error found by the iterator.
+func (it *ScanIterator) Err() error {
+	return it.err
+}
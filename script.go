@@ -0,0 +1,124 @@
+// Copyright (c) 2015 José Carlos Nieto, https://menteslibres.net/xiam
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the
+// "Software"), to deal in the Software without restriction, including
+// without limitation the rights to use, copy, modify, merge, publish,
+// distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to
+// the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+// LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+// WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package resp
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+)
+
+// Script is a Lua script that is run with EVALSHA, so its source is only sent
+// to the server when the server doesn't have it cached yet.
+type Script struct {
+	src  string
+	hash string
+}
+
+// NewScript creates a Script with the given source.
+func NewScript(src string) *Script {
+	h := sha1.Sum([]byte(src))
+	return &Script{
+		src:  src,
+		hash: hex.EncodeToString(h[:]),
+	}
+}
+
+// Hash returns the SHA1 digest of the script, as used by EVALSHA.
+func (s *Script) Hash() string {
+	return s.hash
+}
+
+// EvalSHA returns the EVALSHA command that runs the script.
+func (s *Script) EvalSHA(keys []string, args ...interface{}) *Cmd {
+	return keysCmd("EVALSHA", s.hash, keys, args)
+}
+
+// Eval returns the EVAL command that sends and runs the script.
+func (s *Script) Eval(keys []string, args ...interface{}) *Cmd {
+	return keysCmd("EVAL", s.src, keys, args)
+}
+
+// Load sends the script to the server with SCRIPT LOAD, writing the command to
+// e and reading the reply from d.
+func (s *Script) Load(e *Encoder, d *Decoder) error {
+	var hash string
+	if err := Do(e, d, NewCmd("SCRIPT", "LOAD", s.src), &hash); err != nil {
+		return err
+	}
+
+	if hash != s.hash {
+		return ErrInvalidInput
+	}
+
+	return nil
+}
+
+// Run runs the script with EVALSHA, or with EVAL if the server replies with a
+// NOSCRIPT error, writing commands to e and reading replies from d. The reply
+// is stored in the value pointed to by dst with the same conversions as
+// Unmarshal, a *Message can be used to keep nested tables as they are.
+func (s *Script) Run(e *Encoder, d *Decoder, dst interface{}, keys []string, args ...interface{}) error {
+	var reply Message
+
+	if err := e.Encode(s.EvalSHA(keys, args...)); err != nil {
+		return err
+	}
+
+	if err := d.Decode(&reply); err != nil {
+		return err
+	}
+
+	if reply.Type == ErrorHeader && ErrorCode(reply.Error) == "NOSCRIPT" {
+		if err := e.Encode(s.Eval(keys, args...)); err != nil {
+			return err
+		}
+		if err := d.Decode(&reply); err != nil {
+			return err
+		}
+	}
+
+	if reply.Type == ErrorHeader {
+		return reply.Error
+	}
+
+	return messageToValue(&reply, dst)
+}
+
+// FCall returns an FCALL command that calls a function (redis 7 and newer).
+func FCall(function string, keys []string, args ...interface{}) *Cmd {
+	return keysCmd("FCALL", function, keys, args)
+}
+
+// FCallRO returns an FCALL_RO command that calls a read-only function.
+func FCallRO(function string, keys []string, args ...interface{}) *Cmd {
+	return keysCmd("FCALL_RO", function, keys, args)
+}
+
+// Returns a command like EVAL or FCALL, where the number of keys is followed by
+// the keys and the rest of the arguments.
+func keysCmd(name, target string, keys []string, args []interface{}) *Cmd {
+	c := NewCmd(name, target, len(keys))
+	for i := range keys {
+		c.Arg(keys[i])
+	}
+	return c.Arg(args...)
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2015 José Carlos Nieto, https://menteslibres.net/xiam
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the
+// "Software"), to deal in the Software without restriction, including
+// without limitation the rights to use, copy, modify, merge, publish,
+// distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to
+// the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+// LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+// WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package resp
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// SentinelMasterAddr asks a sentinel for the address of the master called
+// name with SENTINEL GET-MASTER-ADDR-BY-NAME, writing the command to e and
+// reading the reply from d. The sentinel may be wrong during a failover, so
+// callers should check the role of the returned node with Role before using
+// it.
+func SentinelMasterAddr(e *Encoder, d *Decoder, name string) (string, error) {
+	var reply Message
+	if err := roundTrip(e, d, NewCmd("SENTINEL", "GET-MASTER-ADDR-BY-NAME", name), &reply); err != nil {
+		return "", err
+	}
+
+	if reply.IsNil {
+		return "", fmt.Errorf(ErrUnknownMaster.Error(), name)
+	}
+
+	var hostPort []string
+	if err := messageToValue(&reply, &hostPort); err != nil {
+		return "", err
+	}
+
+	if len(hostPort) != 2 {
+		return "", ErrInvalidInput
+	}
+
+	return net.JoinHostPort(hostPort[0], hostPort[1]), nil
+}
+
+// Role returns the role of a node ("master", "slave" or "sentinel") as told
+// by ROLE, writing the command to e and reading the reply from d.
+func Role(e *Encoder, d *Decoder) (string, error) {
+	var reply Message
+	if err := roundTrip(e, d, NewCmd("ROLE"), &reply); err != nil {
+		return "", err
+	}
+
+	a, err := reply.Slice()
+	if err != nil {
+		return "", err
+	}
+
+	if len(a) == 0 {
+		return "", ErrInvalidInput
+	}
+
+	return a[0].Str()
+}
+
+// SwitchMaster is the payload of the +switch-master event that sentinels
+// publish after a failover.
+type SwitchMaster struct {
+	Name    string
+	OldAddr string
+	NewAddr string
+}
+
+// ParseSwitchMaster parses the payload of a +switch-master event, which has
+// the form "<name> <old ip> <old port> <new ip> <new port>".
+func ParseSwitchMaster(payload string) (*SwitchMaster, error) {
+	fields := strings.Fields(payload)
+	if len(fields) != 5 {
+		return nil, ErrInvalidInput
+	}
+
+	return &SwitchMaster{
+		Name:    fields[0],
+		OldAddr: net.JoinHostPort(fields[1], fields[2]),
+		NewAddr: net.JoinHostPort(fields[3], fields[4]),
+	}, nil
+}
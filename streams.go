@@ -0,0 +1,413 @@
+// Copyright (c) 2015 José Carlos Nieto, https://menteslibres.net/xiam
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the
+// "Software"), to deal in the Software without restriction, including
+// without limitation the rights to use, copy, modify, merge, publish,
+// distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to
+// the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+// LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+// WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package resp
+
+import (
+	"context"
+	"time"
+)
+
+// XMessage is an entry of a stream, as returned by XRANGE, XREAD and friends.
+// Values is nil for entries that were deleted.
+type XMessage struct {
+	ID     string
+	Values map[string]string
+}
+
+// UnmarshalRESP decodes an [id, [field, value, ...]] array.
+func (x *XMessage) UnmarshalRESP(m *Message) error {
+	*x = XMessage{}
+
+	a, err := m.Slice()
+	if err != nil {
+		return err
+	}
+
+	if len(a) != 2 {
+		return ErrInvalidInput
+	}
+
+	if x.ID, err = a[0].Str(); err != nil {
+		return err
+	}
+
+	if a[1].IsNil {
+		return nil
+	}
+
+	var kv map[string]*Message
+	if kv, err = a[1].Map(); err != nil {
+		return err
+	}
+
+	x.Values = make(map[string]string, len(kv))
+	for k := range kv {
+		if x.Values[k], err = kv[k].Str(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// XStream holds the entries read from a stream by XREAD or XREADGROUP.
+type XStream struct {
+	Stream   string
+	Messages []XMessage
+}
+
+// UnmarshalRESP decodes a [stream, [entry, ...]] array.
+func (x *XStream) UnmarshalRESP(m *Message) error {
+	*x = XStream{}
+
+	a, err := m.Slice()
+	if err != nil {
+		return err
+	}
+
+	if len(a) != 2 {
+		return ErrInvalidInput
+	}
+
+	if x.Stream, err = a[0].Str(); err != nil {
+		return err
+	}
+
+	return unmarshalXMessages(a[1], &x.Messages)
+}
+
+func unmarshalXMessages(m *Message, dst *[]XMessage) error {
+	a, err := m.Slice()
+	if err != nil {
+		return err
+	}
+
+	*dst = make([]XMessage, len(a))
+	for i := range a {
+		if err = (*dst)[i].UnmarshalRESP(a[i]); err != nil && err != ErrMessageIsNil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// XPending is the summary of the pending entries of a consumer group, as
+// returned by XPENDING.
+type XPending struct {
+	Count     int64
+	Lower     string
+	Higher    string
+	Consumers map[string]int64
+}
+
+// UnmarshalRESP decodes a [count, lower, higher, [[consumer, count], ...]]
+// array.
+func (x *XPending) UnmarshalRESP(m *Message) error {
+	*x = XPending{}
+
+	a, err := m.Slice()
+	if err != nil {
+		return err
+	}
+
+	if len(a) != 4 {
+		return ErrInvalidInput
+	}
+
+	if x.Count, err = a[0].Int64(); err != nil {
+		return err
+	}
+
+	if x.Count == 0 {
+		// Lower, higher and consumers are nil.
+		return nil
+	}
+
+	if x.Lower, err = a[1].Str(); err != nil {
+		return err
+	}
+
+	if x.Higher, err = a[2].Str(); err != nil {
+		return err
+	}
+
+	var consumers []*Message
+	if consumers, err = a[3].Slice(); err != nil {
+		return err
+	}
+
+	x.Consumers = make(map[string]int64, len(consumers))
+	for i := range consumers {
+		var pair []*Message
+		if pair, err = consumers[i].Slice(); err != nil {
+			return err
+		}
+		if len(pair) != 2 {
+			return ErrInvalidInput
+		}
+		var name string
+		var count int64
+		if name, err = pair[0].Str(); err != nil {
+			return err
+		}
+		if count, err = pair[1].Int64(); err != nil {
+			return err
+		}
+		x.Consumers[name] = count
+	}
+
+	return nil
+}
+
+// XPendingEntry is a pending entry of a consumer group, as returned by
+// XPENDING with a range of IDs.
+type XPendingEntry struct {
+	ID         string
+	Consumer   string
+	Idle       time.Duration
+	RetryCount int64
+}
+
+// UnmarshalRESP decodes an [id, consumer, idle, deliveries] array.
+func (x *XPendingEntry) UnmarshalRESP(m *Message) error {
+	*x = XPendingEntry{}
+
+	a, err := m.Slice()
+	if err != nil {
+		return err
+	}
+
+	if len(a) != 4 {
+		return ErrInvalidInput
+	}
+
+	if x.ID, err = a[0].Str(); err != nil {
+		return err
+	}
+
+	if x.Consumer, err = a[1].Str(); err != nil {
+		return err
+	}
+
+	var idle int64
+	if idle, err = a[2].Int64(); err != nil {
+		return err
+	}
+	x.Idle = time.Duration(idle) * time.Millisecond
+
+	if x.RetryCount, err = a[3].Int64(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// XAutoClaimResult is the reply to XAUTOCLAIM. Deleted is only set by redis 7
+// and newer.
+type XAutoClaimResult struct {
+	Next     string
+	Messages []XMessage
+	Deleted  []string
+}
+
+// UnmarshalRESP decodes a [next, [entry, ...], [id, ...]] array.
+func (x *XAutoClaimResult) UnmarshalRESP(m *Message) error {
+	*x = XAutoClaimResult{}
+
+	a, err := m.Slice()
+	if err != nil {
+		return err
+	}
+
+	if len(a) < 2 {
+		return ErrInvalidInput
+	}
+
+	if x.Next, err = a[0].Str(); err != nil {
+		return err
+	}
+
+	if err = unmarshalXMessages(a[1], &x.Messages); err != nil {
+		return err
+	}
+
+	if len(a) > 2 {
+		var deleted []*Message
+		if deleted, err = a[2].Slice(); err != nil {
+			return err
+		}
+		x.Deleted = make([]string, len(deleted))
+		for i := range deleted {
+			if x.Deleted[i], err = deleted[i].Str(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// XAdd returns an XADD command that appends the given field/value pairs to a
+// stream. Use "*" as id to let the server choose it.
+func XAdd(stream, id string, values ...interface{}) *Cmd {
+	return NewCmd("XADD", stream, id).Pairs(values...)
+}
+
+// XRange returns an XRANGE command, use Opt("COUNT", n) to limit the number of
+// entries.
+func XRange(stream, start, end string) *Cmd {
+	return NewCmd("XRANGE", stream, start, end)
+}
+
+// XReadArgs holds the arguments of XREAD and XREADGROUP.
+type XReadArgs struct {
+	// Group and Consumer are only used by XREADGROUP.
+	Group    string
+	Consumer string
+	// Streams to read from, and the ID to read after for each stream. If IDs
+	// is empty "$" is used for XREAD and ">" for XREADGROUP.
+	Streams []string
+	IDs     []string
+	Count   int64
+	// Block is the maximum time to wait for entries. Zero means not blocking
+	// and a negative duration means waiting forever.
+	Block time.Duration
+	// NoAck is only used by XREADGROUP.
+	NoAck bool
+}
+
+func (a *XReadArgs) appendTo(c *Cmd, defaultID string) *Cmd {
+	if a.Count > 0 {
+		c.Opt("COUNT", a.Count)
+	}
+	if a.Block > 0 {
+		c.Opt("BLOCK", int64(a.Block/time.Millisecond))
+	} else if a.Block < 0 {
+		c.Opt("BLOCK", 0)
+	}
+	c.Flag("NOACK", a.NoAck)
+	c.Flag("STREAMS", true)
+	for i := range a.Streams {
+		c.Arg(a.Streams[i])
+	}
+	if len(a.IDs) == 0 {
+		for range a.Streams {
+			c.Arg(defaultID)
+		}
+		return c
+	}
+	for i := range a.IDs {
+		c.Arg(a.IDs[i])
+	}
+	return c
+}
+
+// XRead returns an XREAD command. The reply can be decoded into a []XStream,
+// and is nil if the command timed out.
+func XRead(a XReadArgs) *Cmd {
+	a.NoAck = false
+	return a.appendTo(NewCmd("XREAD"), "$")
+}
+
+// XReadGroup returns an XREADGROUP command. The reply can be decoded into a
+// []XStream, and is nil if the command timed out.
+func XReadGroup(a XReadArgs) *Cmd {
+	return a.appendTo(NewCmd("XREADGROUP", "GROUP", a.Group, a.Consumer), ">")
+}
+
+// XAck returns an XACK command.
+func XAck(stream, group string, ids ...string) *Cmd {
+	c := NewCmd("XACK", stream, group)
+	for i := range ids {
+		c.Arg(ids[i])
+	}
+	return c
+}
+
+// XPendingSummary returns an XPENDING command whose reply can be decoded into
+// an XPending.
+func XPendingSummary(stream, group string) *Cmd {
+	return NewCmd("XPENDING", stream, group)
+}
+
+// XPendingRange returns an XPENDING command whose reply can be decoded into a
+// []XPendingEntry.
+func XPendingRange(stream, group, start, end string, count int64) *Cmd {
+	return NewCmd("XPENDING", stream, group, start, end, count)
+}
+
+// XAutoClaim returns an XAUTOCLAIM command whose reply can be decoded into an
+// XAutoClaimResult.
+func XAutoClaim(stream, group, consumer string, minIdle time.Duration, start string, count int64) *Cmd {
+	return NewCmd("XAUTOCLAIM", stream, group, consumer, int64(minIdle/time.Millisecond), start).Opt("COUNT", count)
+}
+
+// XConsume reads entries for a consumer group with XREADGROUP in a loop,
+// writing commands to e and reading replies from d, and calls handle for each
+// entry. Entries for which handle returns nil are acknowledged with XACK,
+// unless a.NoAck is set. A non-nil error from handle stops the loop and is
+// returned. If a.Block is zero XREADGROUP blocks until there are new entries,
+// so the loop doesn't poll the server.
+//
+// XConsume runs until ctx is done, in which case ctx.Err() is returned. See
+// EncodeContext and DecodeContext for the streams that can be interrupted
+// while blocked. The reply to an interrupted XREADGROUP could still be sent by
+// the server, so the connection should be closed afterwards.
+func XConsume(ctx context.Context, e *Encoder, d *Decoder, a XReadArgs, handle func(stream string, msg XMessage) error) error {
+	if a.Block == 0 {
+		a.Block = -1
+	}
+
+	for {
+		if err := e.EncodeContext(ctx, XReadGroup(a)); err != nil {
+			return err
+		}
+
+		var streams []XStream
+		if err := d.DecodeContext(ctx, &streams); err != nil && err != ErrMessageIsNil {
+			return err
+		}
+
+		for _, s := range streams {
+			for _, msg := range s.Messages {
+				if err := handle(s.Stream, msg); err != nil {
+					return err
+				}
+				if a.NoAck {
+					continue
+				}
+				if err := e.EncodeContext(ctx, XAck(s.Stream, a.Group, msg.ID)); err != nil {
+					return err
+				}
+				var n int64
+				if err := d.DecodeContext(ctx, &n); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright (c) 2015 José Carlos Nieto, https://menteslibres.net/xiam
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the
+// "Software"), to deal in the Software without restriction, including
+// without limitation the rights to use, copy, modify, merge, publish,
+// distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to
+// the following conditions:
+//
+// The above copyright notice and this permission notice shall be
+// included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+// MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+// NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+// LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+// WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package resp
+
+import (
+	"io"
+)
+
+// TokenType is the type of a Token.
+type TokenType int
+
+const (
+	// StatusToken is a status message.
+	StatusToken TokenType = iota + 1
+	// ErrorToken is an error message.
+	ErrorToken
+	// IntegerToken is an integer message.
+	IntegerToken
+	// BulkToken is the beginning of a bulk message. Its contents can be read
+	// with Decoder.BulkBytes.
+	BulkToken
+	// NilToken is a nil bulk message or a nil array.
+	NilToken
+	// ArrayStartToken is the beginning of an array.
+	ArrayStartToken
+	// ArrayEndToken is the end of an array, it follows the last element of the
+	// array.
+	ArrayEndToken
+)
+
+// Token holds a value of one of these types:
+//
+//	StatusToken: Bytes holds the status.
+//	ErrorToken: Bytes holds the error message.
+//	IntegerToken: Integer holds the integer.
+//	BulkToken: Len holds the length of the bulk message.
+//	NilToken
+//	ArrayStartToken: Len holds the number of elements in the array.
+//	ArrayEndToken
+//
+// Bytes is only valid until the next call to any of the Decoder methods.
+type Token struct {
+	Type    TokenType
+	Len     int
+	Integer int64
+	Bytes   []byte
+}
+
+// Prepares the decoder for reading the next value. Bulk contents that were not
+// read after a BulkToken are discarded and arrays that were completely read are
+// closed.
+func (d *Decoder) begin() error {
+	d.buf = d.buf[:0]
+	d.arrayStarted = false
+
+	if err := d.discardPendingBulk(); err != nil {
+		return err
+	}
+
+	for len(d.arrays) > 0 && d.arrays[len(d.arrays)-1] == 0 {
+		d.arrays = d.arrays[:len(d.arrays)-1]
+	}
+
+	// Wait for the next value, errors up to this point don't leave the decoder
+	// in the middle of a message.
+	if _, err := d.r.br.Peek(1); err != nil {
+		d.idle = true
+		return err
+	}
+
+	if len(d.arrays) > 0 {
+		d.arrays[len(d.arrays)-1]--
+	}
+
+	return nil
+}
+
+func (d *Decoder) discardPendingBulk() error {
+	if d.pendingBulk < 0 {
+		return nil
+	}
+	n := d.pendingBulk
+	d.pendingBulk = -1
+	return d.r.discardMessageBytes(n)
+}
+
+// Token returns the next RESP token in the input stream. At the end of the
+// input stream, Token returns io.EOF.
+//
+// Token allows reading messages without decoding them as a whole. Arrays are
+// returned as an ArrayStartToken followed by the tokens of its elements and an
+// ArrayEndToken. The contents of bulk messages are not read until BulkBytes is
+// called, and are discarded otherwise.
+//
+// Token can be mixed with calls to Decode and friends, which decode the next
+// whole message, for instance, the next element of an array.
+func (d *Decoder) Token() (Token, error) {
+	if d.err != nil {
+		return Token{}, ErrDecoderPoisoned
+	}
+	tok, err := d.token()
+	return tok, d.fail(err)
+}
+
+func (d *Decoder) token() (Token, error) {
+	if err := d.discardPendingBulk(); err != nil {
+		return Token{}, err
+	}
+
+	if len(d.arrays) > 0 && d.arrays[len(d.arrays)-1] == 0 {
+		d.arrays = d.arrays[:len(d.arrays)-1]
+		d.arrayStarted = false
+		return Token{Type: ArrayEndToken}, nil
+	}
+
+	if err := d.begin(); err != nil {
+		return Token{}, err
+	}
+
+	lineType, line, err := d.r.ReadLine()
+	if err != nil {
+		return Token{}, err
+	}
+
+	switch lineType {
+
+	case StringHeader:
+		return Token{Type: StatusToken, Bytes: line}, nil
+
+	case ErrorHeader:
+		return Token{Type: ErrorToken, Bytes: line}, nil
+
+	case IntegerHeader:
+		var n int64
+		if n, err = parseInt(line); err != nil {
+			return Token{}, err
+		}
+		return Token{Type: IntegerToken, Integer: n}, nil
+
+	case BulkHeader:
+		var msgLen int
+		if msgLen, err = parseLength(line); err != nil {
+			return Token{}, err
+		}
+		if msgLen > bulkMessageMaxLength {
+			return Token{}, ErrMessageIsTooLarge
+		}
+		if msgLen < 0 {
+			return Token{Type: NilToken}, nil
+		}
+		d.pendingBulk = msgLen
+		return Token{Type: BulkToken, Len: msgLen}, nil
+
+	case ArrayHeader:
+		var arrLen int
+		if arrLen, err = parseLength(line); err != nil {
+			return Token{}, err
+		}
+		if arrLen < 0 {
+			return Token{Type: NilToken}, nil
+		}
+		d.arrays = append(d.arrays, arrLen)
+		d.arrayStarted = true
+		return Token{Type: ArrayStartToken, Len: arrLen}, nil
+	}
+
+	return Token{}, ErrInvalidInput
+}
+
+// Peek returns the header of the next message without consuming it. For bulk
+// messages and arrays Peek also returns their declared length, which is -1 for
+// nil values. Contents of a bulk message that were not read after a BulkToken
+// are discarded.
+func (d *Decoder) Peek() (lineType byte, n int, err error) {
+	if d.err != nil {
+		return 0, 0, ErrDecoderPoisoned
+	}
+	if err = d.discardPendingBulk(); err != nil {
+		return 0, 0, d.fail(err)
+	}
+	if lineType, n, err = d.r.Peek(); err != nil {
+		if err == io.EOF && !d.inArray() {
+			return 0, 0, err
+		}
+		// Peek doesn't consume any input, only an unfinished array poisons the
+		// decoder.
+		d.idle = true
+		return 0, 0, d.fail(err)
+	}
+	return lineType, n, nil
+}
+
+// Reports whether the decoder is in the middle of an array.
+func (d *Decoder) inArray() bool {
+	for i := range d.arrays {
+		if d.arrays[i] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Buffered returns the number of bytes that can be read from the current
+// buffer without reading from the input stream.
+func (d *Decoder) Buffered() int {
+	return d.r.Buffered()
+}
+
+// BulkBytes reads the contents of the bulk message whose BulkToken was the last
+// token returned by Token and stores them into dst. If dst is not large enough
+// to hold the contents a new slice is allocated.
+func (d *Decoder) BulkBytes(dst []byte) ([]byte, error) {
+	if d.err != nil {
+		return nil, ErrDecoderPoisoned
+	}
+	if d.pendingBulk < 0 {
+		return nil, ErrNoBulkToken
+	}
+	n := d.pendingBulk
+	d.pendingBulk = -1
+	buf, err := d.r.readMessageBytes(dst, n)
+	if err != nil {
+		return nil, d.fail(err)
+	}
+	return buf, nil
+}
+
+// Skip discards the rest of the value whose first token was the last token
+// returned by Token. After an ArrayStartToken Skip discards all the remaining
+// elements of the array, including its ArrayEndToken, and after a BulkToken it
+// discards the contents of the bulk message. Skip does nothing after other
+// tokens.
+func (d *Decoder) Skip() error {
+	if d.err != nil {
+		return ErrDecoderPoisoned
+	}
+
+	if !d.arrayStarted {
+		return d.fail(d.discardPendingBulk())
+	}
+
+	depth := len(d.arrays)
+	for len(d.arrays) >= depth {
+		if _, err := d.Token(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}